@@ -0,0 +1,731 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"syscall/js"
+)
+
+// The JavaScript side of the wasmGraph API.  Each function in names is turned into one returning a Promise, which
+// hands its resolve and reject functions to call along with the function name and arguments.  Event listeners are kept
+// here, with watch told which events have any so the wasm module only sends those.  This is used on the page directly,
+// and is also sent to the page when running in a worker, so both get the same API
+const apiShim = `
+const listeners = {};
+let nextID = 1;
+const watched = () => watch(Object.keys(listeners).filter(e => Object.keys(listeners[e]).length > 0));
+const api = {
+	on(event, fn) {
+		if (!events.includes(event)) {
+			throw new TypeError('unknown event "' + event + '", should be one of: ' + events.join(', '));
+		}
+		if (typeof fn !== 'function') {
+			throw new TypeError('the listener should be a function');
+		}
+		const id = nextID++;
+		(listeners[event] = listeners[event] || {})[id] = fn;
+		watched();
+		return id;
+	},
+	off(id) {
+		Object.values(listeners).forEach(l => delete l[id]);
+		watched();
+	},
+};
+names.forEach(n => api[n] = (...args) => new Promise((resolve, reject) => call(resolve, reject, n, args)));
+const emit = (event, data) => Object.values(listeners[event] || {}).forEach(fn => fn(data));
+return { api: api, emit: emit };
+`
+
+// The events host pages can subscribe to with wasmGraph.on()
+var apiEvents = []string{"operationstart", "operationcomplete", "selectionchange", "frame"}
+
+// The operations which can be queued with wasmGraph.operation(), by name
+var apiOperations = map[string]OperationType{
+	"rotate":       ROTATE,
+	"scale":        SCALE,
+	"translate":    TRANSLATE,
+	"fit":          FIT,
+	"loop":         LOOP,
+	"catmullClark": CATMULLCLARK,
+	"smooth":       SMOOTH,
+	"union":        UNION,
+	"intersect":    INTERSECT,
+	"difference":   DIFFERENCE,
+}
+
+var (
+	apiCallback  js.Callback
+	apiWatchCall js.Callback
+	apiEmitFn    js.Value            // Calls the listeners for an event, when running on the page
+	apiWatched   = map[string]bool{} // The events with listeners
+	apiOps       chan Operation      // Operations queued by the API, waiting for their turn
+	apiDuration  = 1000.0            // Default time operations queued by the API take, in milliseconds
+	apiMaxQueued = 100               // Number of operations the API can have waiting at once
+)
+
+// A function of the API.  The arguments are the ones the JavaScript function was called with, and the result goes to
+// reply, which resolves (or on error, rejects) the Promise the function returned
+type apiFunction func(args apiArgs) (interface{}, error)
+
+// Operations complete later, so the functions queueing them reply themselves
+type apiAsyncFunction func(args apiArgs, reply func(interface{}, error))
+
+var apiFunctions map[string]apiAsyncFunction
+
+func init() {
+	// Most functions reply straight away
+	sync := func(fn apiFunction) apiAsyncFunction {
+		return func(args apiArgs, reply func(interface{}, error)) {
+			reply(fn(args))
+		}
+	}
+	apiFunctions = map[string]apiAsyncFunction{
+		"objects":      sync(apiObjects),
+		"addObject":    sync(apiAddObject),
+		"removeObject": sync(apiRemoveObject),
+		"points":       sync(apiPoints),
+		"select":       sync(apiSelect),
+		"camera":       sync(apiCamera),
+		"setCamera":    sync(apiSetCamera),
+		"operation":    apiOperation,
+	}
+}
+
+// Sets up the wasmGraph API.  On the page it's added to the window object, and when running in a worker it's sent to
+// the page along with the ready message instead (see initWorker)
+func initAPI() {
+	apiOps = make(chan Operation, apiMaxQueued)
+	go func() {
+		for op := range apiOps {
+			queue <- op
+		}
+	}()
+	if inWorker {
+		return
+	}
+	apiCallback = js.NewCallback(func(args []js.Value) {
+		resolve, reject := args[0], args[1]
+		apiCall(args[2].String(), args[3], func(value interface{}, err error) {
+			if err != nil {
+				reject.Invoke(js.Global().Get("Error").New(err.Error()))
+				return
+			}
+			resolve.Invoke(value)
+		})
+	})
+	apiWatchCall = js.NewCallback(func(args []js.Value) {
+		apiWatch(args[0])
+	})
+	desc := apiDescription()
+	shim := js.Global().Get("Function").New("names", "events", "call", "watch", desc["shim"])
+	res := shim.Invoke(desc["names"], desc["events"], apiCallback, apiWatchCall)
+	apiEmitFn = res.Get("emit")
+	js.Global().Set("wasmGraph", res.Get("api"))
+}
+
+// Returns what the page needs to build the API when running in a worker.  The page passes the calls across as
+// messages (see messageHandler)
+func apiDescription() map[string]interface{} {
+	var names, events []interface{}
+	for n := range apiFunctions {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i].(string) < names[j].(string) })
+	for _, e := range apiEvents {
+		events = append(events, e)
+	}
+	return map[string]interface{}{"shim": apiShim, "names": names, "events": events}
+}
+
+// Runs the named API function
+func apiCall(name string, args js.Value, reply func(interface{}, error)) {
+	fn, ok := apiFunctions[name]
+	if !ok {
+		reply(nil, fmt.Errorf("unknown function: %v", name))
+		return
+	}
+	fn(apiArgs{fn: name, v: args}, reply)
+}
+
+// Records which events have listeners, from an array of event names
+func apiWatch(events js.Value) {
+	apiWatched = map[string]bool{}
+	for i := 0; i < events.Length(); i++ {
+		apiWatched[events.Index(i).String()] = true
+	}
+}
+
+// Sends an event to its listeners, if there are any
+func apiEmit(event string, data map[string]interface{}) {
+	if !apiWatched[event] {
+		return
+	}
+	if inWorker {
+		js.Global().Call("postMessage", map[string]interface{}{"type": "apiEvent", "event": event, "data": data})
+		return
+	}
+	apiEmitFn.Invoke(event, data)
+}
+
+// The arguments of an API call, with helpers to check them
+type apiArgs struct {
+	fn string   // Name of the function, for error messages
+	v  js.Value // The arguments array
+}
+
+// Returns the given argument, or undefined if it wasn't given
+func (a apiArgs) get(i int) js.Value {
+	if i >= a.v.Length() {
+		return js.Undefined()
+	}
+	return a.v.Index(i)
+}
+
+// Returns an argument which should be a string
+func (a apiArgs) string(i int, what string) (string, error) {
+	v := a.get(i)
+	if v.Type() != js.TypeString {
+		return "", fmt.Errorf("%v: %v should be a string", a.fn, what)
+	}
+	return v.String(), nil
+}
+
+// Returns an argument which should be an object, or an empty one if it wasn't given
+func (a apiArgs) options(i int) (apiOptions, error) {
+	v := a.get(i)
+	switch v.Type() {
+	case js.TypeUndefined, js.TypeNull:
+		return apiOptions{fn: a.fn, v: js.Global().Get("Object").New()}, nil
+	case js.TypeObject:
+		return apiOptions{fn: a.fn, v: v}, nil
+	}
+	return apiOptions{}, fmt.Errorf("%v: the options should be an object", a.fn)
+}
+
+// The fields of an options object passed to an API function
+type apiOptions struct {
+	fn string
+	v  js.Value
+}
+
+// Returns whether the field was given
+func (o apiOptions) has(field string) bool {
+	t := o.v.Get(field).Type()
+	return t != js.TypeUndefined && t != js.TypeNull
+}
+
+// Returns a number field, or def when it isn't given
+func (o apiOptions) number(field string, def float64) (float64, error) {
+	if !o.has(field) {
+		return def, nil
+	}
+	v := o.v.Get(field)
+	if v.Type() != js.TypeNumber || math.IsNaN(v.Float()) || math.IsInf(v.Float(), 0) {
+		return 0, fmt.Errorf("%v: %v should be a number", o.fn, field)
+	}
+	return v.Float(), nil
+}
+
+// Returns a string field, or def when it isn't given
+func (o apiOptions) string(field string, def string) (string, error) {
+	if !o.has(field) {
+		return def, nil
+	}
+	v := o.v.Get(field)
+	if v.Type() != js.TypeString {
+		return "", fmt.Errorf("%v: %v should be a string", o.fn, field)
+	}
+	return v.String(), nil
+}
+
+// Returns a boolean field, or false when it isn't given
+func (o apiOptions) bool(field string) (bool, error) {
+	if !o.has(field) {
+		return false, nil
+	}
+	v := o.v.Get(field)
+	if v.Type() != js.TypeBoolean {
+		return false, fmt.Errorf("%v: %v should be true or false", o.fn, field)
+	}
+	return v.Bool(), nil
+}
+
+// Returns a field holding an array of n numbers, or def when it isn't given
+func (o apiOptions) numbers(field string, n int, def []float64) ([]float64, error) {
+	if !o.has(field) {
+		return def, nil
+	}
+	nums, ok := apiNumbers(o.v.Get(field))
+	if !ok || len(nums) != n {
+		return nil, fmt.Errorf("%v: %v should be an array of %d numbers", o.fn, field, n)
+	}
+	return nums, nil
+}
+
+// Returns the numbers in a JavaScript array.  The second return value is false if it isn't an array of numbers
+func apiNumbers(v js.Value) ([]float64, bool) {
+	if v.Type() != js.TypeObject || !v.InstanceOf(js.Global().Get("Array")) {
+		return nil, false
+	}
+	var nums []float64
+	for i := 0; i < v.Length(); i++ {
+		n := v.Index(i)
+		if n.Type() != js.TypeNumber || math.IsNaN(n.Float()) || math.IsInf(n.Float(), 0) {
+			return nil, false
+		}
+		nums = append(nums, n.Float())
+	}
+	return nums, true
+}
+
+// Returns the lists of point indexes in a field (eg the edges or surfaces), checking each has at least min points and
+// only uses points which exist
+func (o apiOptions) indexLists(field string, min int, points int) ([][]int, error) {
+	if !o.has(field) {
+		return nil, nil
+	}
+	v := o.v.Get(field)
+	if v.Type() != js.TypeObject || !v.InstanceOf(js.Global().Get("Array")) {
+		return nil, fmt.Errorf("%v: %v should be an array of arrays of point indexes", o.fn, field)
+	}
+	var lists [][]int
+	for i := 0; i < v.Length(); i++ {
+		nums, ok := apiNumbers(v.Index(i))
+		if !ok || len(nums) < min {
+			return nil, fmt.Errorf("%v: %v[%d] should be an array of at least %d point indexes", o.fn, field, i, min)
+		}
+		var l []int
+		for _, n := range nums {
+			if n != math.Trunc(n) || n < 0 || int(n) >= points {
+				return nil, fmt.Errorf("%v: %v[%d] uses point %v, which doesn't exist", o.fn, field, i, n)
+			}
+			l = append(l, int(n))
+		}
+		lists = append(lists, l)
+	}
+	return lists, nil
+}
+
+// Returns the scene node for an object named in an API call
+func apiNode(fn string, name string) (*Node, error) {
+	n := findNode(sceneRoot, name)
+	if n == nil || n == sceneRoot {
+		return nil, fmt.Errorf("%v: unknown object: %v", fn, name)
+	}
+	return n, nil
+}
+
+// Returns an error if an operation is changing the scene, so it's not safe to change it from the API
+func apiBusy(fn string) error {
+	if renderActive.Load() {
+		return fmt.Errorf("%v: an operation is in progress, try again when it's finished", fn)
+	}
+	return nil
+}
+
+// wasmGraph.objects() lists the objects in the scene, in scene graph order
+func apiObjects(args apiArgs) (interface{}, error) {
+	list := []interface{}{}
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n != sceneRoot {
+			o := map[string]interface{}{"name": n.Name, "parent": "", "points": 0}
+			if n.Parent != sceneRoot {
+				o["parent"] = n.Parent.Name
+			}
+			if n.Mesh != nil {
+				o["mesh"] = n.Mesh.Name
+				o["points"] = len(n.Mesh.P)
+			}
+			list = append(list, o)
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(sceneRoot)
+	return list, nil
+}
+
+// wasmGraph.addObject(options) adds an object to the scene, resolving to its name.  The options give either the name
+// of a primitive, or the points (as [x, y, z] arrays) along with any edges and surfaces (as arrays of point indexes).
+// The name, parent, position ([x, y, z]) and colour are optional
+func apiAddObject(args apiArgs) (interface{}, error) {
+	opts, err := args.options(0)
+	if err != nil {
+		return nil, err
+	}
+	if err = apiBusy(args.fn); err != nil {
+		return nil, err
+	}
+	var ob Object
+	name := "object"
+	if opts.has("primitive") {
+		p, err := opts.string("primitive", "")
+		if err != nil {
+			return nil, err
+		}
+		gen, ok := primitives[p]
+		if !ok {
+			return nil, fmt.Errorf("%v: unknown primitive '%v', should be one of: %v", args.fn, p,
+				strings.Join(primitiveNames(), ", "))
+		}
+		ob, name = gen(), p
+	} else {
+		if !opts.has("points") {
+			return nil, fmt.Errorf("%v: either a primitive or the points are needed", args.fn)
+		}
+		pts := opts.v.Get("points")
+		if pts.Type() != js.TypeObject || !pts.InstanceOf(js.Global().Get("Array")) || pts.Length() == 0 {
+			return nil, fmt.Errorf("%v: points should be an array of [x, y, z] arrays", args.fn)
+		}
+		for i := 0; i < pts.Length(); i++ {
+			xyz, ok := apiNumbers(pts.Index(i))
+			if !ok || len(xyz) != 3 {
+				return nil, fmt.Errorf("%v: points[%d] should be an array of 3 numbers", args.fn, i)
+			}
+			ob.P = append(ob.P, Point{X: xyz[0], Y: xyz[1], Z: xyz[2]})
+		}
+		edges, err := opts.indexLists("edges", 2, len(ob.P))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range edges {
+			ob.E = append(ob.E, Edge(e))
+		}
+		surfaces, err := opts.indexLists("surfaces", 3, len(ob.P))
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range surfaces {
+			ob.S = append(ob.S, Surface(s))
+		}
+		ob.C = theme.Point
+	}
+	if ob.C, err = opts.string("colour", ob.C); err != nil {
+		return nil, err
+	}
+	if name, err = opts.string("name", name); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%v: the name can't be empty", args.fn)
+	}
+	parent := sceneRoot
+	if opts.has("parent") {
+		p, err := opts.string("parent", "")
+		if err != nil {
+			return nil, err
+		}
+		if parent, err = apiNode(args.fn, p); err != nil {
+			return nil, err
+		}
+	}
+	at, err := opts.numbers("position", 3, []float64{0, 0, 0})
+	if err != nil {
+		return nil, err
+	}
+	name = uniqueMeshName(name)
+	addNode(parent, name, newMesh(name, ob), at[0], at[1], at[2])
+	updateWorldSpace()
+	return name, nil
+}
+
+// wasmGraph.removeObject(name) removes an object, and any objects attached to it, from the scene
+func apiRemoveObject(args apiArgs) (interface{}, error) {
+	name, err := args.string(0, "the object name")
+	if err != nil {
+		return nil, err
+	}
+	n, err := apiNode(args.fn, name)
+	if err != nil {
+		return nil, err
+	}
+	if err = apiBusy(args.fn); err != nil {
+		return nil, err
+	}
+	deleteNode(n)
+	updateWorldSpace()
+	if _, ok := worldSpace[selected]; !ok && selected != "" {
+		selectObject("")
+	}
+	return name, nil
+}
+
+// wasmGraph.points(name) resolves to an object's points, as shown on the graph and in the side panel
+func apiPoints(args apiArgs) (interface{}, error) {
+	name, err := args.string(0, "the object name")
+	if err != nil {
+		return nil, err
+	}
+	o, ok := worldSpace[name]
+	if !ok {
+		return nil, fmt.Errorf("%v: unknown object: %v", args.fn, name)
+	}
+	pts := []interface{}{}
+	for _, p := range o.P {
+		pts = append(pts, map[string]interface{}{"num": p.Num, "x": p.X, "y": p.Y, "z": p.Z})
+	}
+	return pts, nil
+}
+
+// wasmGraph.select(name) selects an object.  An empty name clears the selection
+func apiSelect(args apiArgs) (interface{}, error) {
+	name, err := args.string(0, "the object name")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := worldSpace[name]; !ok && name != "" {
+		return nil, fmt.Errorf("%v: unknown object: %v", args.fn, name)
+	}
+	selectObject(name)
+	return name, nil
+}
+
+// wasmGraph.camera() resolves to the view transform, as a 16 number row major matrix
+func apiCamera(args apiArgs) (interface{}, error) {
+	m := []interface{}{}
+	for _, v := range sceneRoot.Local {
+		m = append(m, v)
+	}
+	return map[string]interface{}{"matrix": m}, nil
+}
+
+// wasmGraph.setCamera(options) sets the view.  Either give the matrix (as from camera()), or any of the rotation
+// around the X, Y, and Z axes in degrees ([x, y, z]), the zoom, and the pan ([x, y]) to apply in that order
+func apiSetCamera(args apiArgs) (interface{}, error) {
+	opts, err := args.options(0)
+	if err != nil {
+		return nil, err
+	}
+	if err = apiBusy(args.fn); err != nil {
+		return nil, err
+	}
+	var m matrix
+	if opts.has("matrix") {
+		nums, err := opts.numbers("matrix", 16, nil)
+		if err != nil {
+			return nil, err
+		}
+		m = nums
+		if _, ok := invertMatrix(m); !ok {
+			return nil, fmt.Errorf("%v: the matrix can't be inverted", args.fn)
+		}
+	} else {
+		rot, err := opts.numbers("rotation", 3, []float64{0, 0, 0})
+		if err != nil {
+			return nil, err
+		}
+		zoom, err := opts.number("zoom", 1)
+		if err != nil {
+			return nil, err
+		}
+		if zoom <= 0 {
+			return nil, fmt.Errorf("%v: zoom should be greater than zero", args.fn)
+		}
+		pan, err := opts.numbers("pan", 2, []float64{0, 0})
+		if err != nil {
+			return nil, err
+		}
+		m = rotateAroundZ(rotateAroundY(rotateAroundX(identityMatrix, rot[0]), rot[1]), rot[2])
+		m = translate(scale(m, zoom, zoom, zoom), pan[0], pan[1], 0)
+	}
+	sceneRoot.Local = m
+	updateWorldSpace()
+	return apiCamera(args)
+}
+
+// wasmGraph.operation(type, options) queues an operation, resolving once it's complete.  The options are the target
+// object (the whole scene when not given) and the other object for union, intersect, and difference, along with x, y,
+// and z amounts for rotate, scale, and translate, and the duration in milliseconds.  Smooth takes the passes and
+// factor, fit takes the objects to fit (an array of names), and keep leaves the objects combined by union, intersect,
+// and difference in the scene
+func apiOperation(args apiArgs, reply func(interface{}, error)) {
+	op, err := apiOperationFrom(args)
+	if err != nil {
+		reply(nil, err)
+		return
+	}
+	op.done = func(result string, err error) {
+		if err != nil {
+			reply(nil, fmt.Errorf("%v: %v", args.fn, err))
+			return
+		}
+		r := apiOperationData(op)
+		if result != "" {
+			r["result"] = result
+		}
+		reply(r, nil)
+	}
+	select {
+	case apiOps <- op:
+	default:
+		reply(nil, fmt.Errorf("%v: too many operations are waiting, try again when some have finished", args.fn))
+	}
+}
+
+// Works out the operation an API call is asking for
+func apiOperationFrom(args apiArgs) (Operation, error) {
+	var op Operation
+	opName, err := args.string(0, "the operation type")
+	if err != nil {
+		return op, err
+	}
+	opType, ok := apiOperations[opName]
+	if !ok {
+		var names []string
+		for n := range apiOperations {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return op, fmt.Errorf("%v: unknown operation '%v', should be one of: %v", args.fn, opName, strings.Join(names, ", "))
+	}
+	opts, err := args.options(1)
+	if err != nil {
+		return op, err
+	}
+
+	// Fitting works out its own zoom and pan from the objects
+	if opType == FIT {
+		var names []string
+		if opts.has("objects") {
+			v := opts.v.Get("objects")
+			if v.Type() != js.TypeObject || !v.InstanceOf(js.Global().Get("Array")) {
+				return op, fmt.Errorf("%v: objects should be an array of object names", args.fn)
+			}
+			for i := 0; i < v.Length(); i++ {
+				n := v.Index(i)
+				if n.Type() != js.TypeString {
+					return op, fmt.Errorf("%v: objects should be an array of object names", args.fn)
+				}
+				if _, ok := worldSpace[n.String()]; !ok {
+					return op, fmt.Errorf("%v: unknown object: %v", args.fn, n.String())
+				}
+				names = append(names, n.String())
+			}
+		}
+		op, ok = fitOperation(names...)
+		if !ok {
+			return op, fmt.Errorf("%v: there are no points to fit", args.fn)
+		}
+		return op, nil
+	}
+
+	op.op = opType
+	if op.target, err = opts.string("target", ""); err != nil {
+		return op, err
+	}
+	if op.target != "" {
+		if _, err = apiNode(args.fn, op.target); err != nil {
+			return op, err
+		}
+	}
+	ms, err := opts.number("duration", apiDuration)
+	if err != nil {
+		return op, err
+	}
+	if ms < 0 {
+		return op, fmt.Errorf("%v: duration can't be negative", args.fn)
+	}
+	op.t = int32(ms)
+	op.f = int32(math.Max(1, math.Round(ms*60/1000)))
+
+	def := 0.0
+	if opType == SCALE {
+		def = 1
+	}
+	switch opType {
+	case ROTATE, SCALE, TRANSLATE:
+		for _, f := range []struct {
+			name string
+			v    *float64
+		}{{"x", &op.X}, {"y", &op.Y}, {"z", &op.Z}} {
+			if *f.v, err = opts.number(f.name, def); err != nil {
+				return op, err
+			}
+		}
+		if opType == SCALE && (op.X <= 0 || op.Y <= 0 || op.Z <= 0) {
+			return op, fmt.Errorf("%v: scale factors should be greater than zero", args.fn)
+		}
+
+	case LOOP, CATMULLCLARK, SMOOTH:
+		if op.target == "" {
+			return op, fmt.Errorf("%v: %v needs a target object", args.fn, opName)
+		}
+		if op.X, err = opts.number("passes", float64(smoothIterations)); err != nil {
+			return op, err
+		}
+		if op.Y, err = opts.number("factor", smoothFactor); err != nil {
+			return op, err
+		}
+
+	case UNION, INTERSECT, DIFFERENCE:
+		if op.target == "" {
+			return op, fmt.Errorf("%v: %v needs a target object", args.fn, opName)
+		}
+		if op.other, err = opts.string("other", ""); err != nil {
+			return op, err
+		}
+		if _, err = apiNode(args.fn, op.other); err != nil {
+			return op, err
+		}
+		if op.other == op.target {
+			return op, fmt.Errorf("%v: the target and other objects should be different", args.fn)
+		}
+		keep, err := opts.bool("keep")
+		if err != nil {
+			return op, err
+		}
+		if !keep {
+			op.X = 1
+		}
+	}
+	return op, nil
+}
+
+// Returns the details of an operation, for events and results
+func apiOperationData(op Operation) map[string]interface{} {
+	name := "reveal"
+	for n, t := range apiOperations {
+		if t == op.op {
+			name = n
+		}
+	}
+	return map[string]interface{}{"type": name, "target": op.target, "other": op.other}
+}
+
+// Lets any listeners know an operation is starting
+func operationStarted(op Operation) {
+	apiEmit("operationstart", apiOperationData(op))
+}
+
+// Lets any listeners, and whatever queued the operation, know it's finished.  The result is the name of any new object
+// it made
+func operationFinished(op Operation, result string, err error) {
+	data := apiOperationData(op)
+	if result != "" {
+		data["result"] = result
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	apiEmit("operationcomplete", data)
+	if op.done != nil {
+		op.done(result, err)
+	}
+}
+
+// Handles an API call the page has passed to the worker, posting the result back
+func apiMessage(data js.Value) {
+	id := data.Get("id")
+	apiCall(data.Get("fn").String(), data.Get("args"), func(value interface{}, err error) {
+		msg := map[string]interface{}{"type": "apiResult", "id": id, "value": value}
+		if err != nil {
+			msg["error"] = err.Error()
+		}
+		js.Global().Call("postMessage", msg)
+	})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// A line to draw on the graph, already projected into screen co-ordinates
+type guideLine struct {
+	x1, y1, x2, y2 float64
+	colour         string
+	width          float64
+}
+
+// A piece of text to draw on the graph, already projected into screen co-ordinates
+type guideLabel struct {
+	x, y   float64
+	colour string
+	font   string
+	text   string
+}
+
+var (
+	showAxes       = true  // If true, the world axes and orientation gizmo are drawn
+	showGroundGrid = false // If true, a grid is drawn on the ground (Z = 0) plane
+	axisLength     = 10.0  // Length of the world axes either side of the origin, in world units
+	groundGridSize = 10.0  // Distance the ground grid extends from the origin, in world units
+	groundGridStep = 1.0   // Spacing between ground grid lines, in world units
+	tickStep       = 2.0   // Spacing between the ground grid tick labels, in world units
+	gizmoLength    = 30.0  // Length of the orientation gizmo axes, in pixels
+)
+
+// Returns the lines and labels for the world axes, ground grid, and orientation gizmo.  These are transformed by the
+// same matrices as the objects, so they rotate along with the scene
+func worldGuides(centerX float64, centerY float64, step float64, left float64, bottom float64) (lines []guideLine, labels []guideLabel) {
+	project := func(p Point) (float64, float64) {
+		t := transform(sceneMatrix, p)
+		return centerX + (t.X * step), centerY + ((t.Y * step) * -1)
+	}
+	addLine := func(a Point, b Point, colour string, w float64) {
+		x1, y1 := project(a)
+		x2, y2 := project(b)
+		lines = append(lines, guideLine{x1: x1, y1: y1, x2: x2, y2: y2, colour: colour, width: w})
+	}
+	addLabel := func(p Point, colour string, font string, text string) {
+		x, y := project(p)
+		labels = append(labels, guideLabel{x: x + 3, y: y - 3, colour: colour, font: font, text: text})
+	}
+	axes := []struct {
+		dir    Point
+		colour string
+		name   string
+	}{
+		{Point{X: 1}, theme.AxisX, "X"},
+		{Point{Y: 1}, theme.AxisY, "Y"},
+		{Point{Z: 1}, theme.AxisZ, "Z"},
+	}
+
+	// The ground grid, with tick labels along the X and Y axes
+	if showGroundGrid {
+		n := int(groundGridSize / groundGridStep)
+		for i := -n; i <= n; i++ {
+			v := float64(i) * groundGridStep
+			addLine(Point{X: v, Y: -groundGridSize}, Point{X: v, Y: groundGridSize}, theme.GroundGrid, 1)
+			addLine(Point{X: -groundGridSize, Y: v}, Point{X: groundGridSize, Y: v}, theme.GroundGrid, 1)
+		}
+		n = int(groundGridSize / tickStep)
+		for i := -n; i <= n; i++ {
+			if i == 0 {
+				continue
+			}
+			v := float64(i) * tickStep
+			addLabel(Point{X: v}, theme.TickLabel, theme.TickFont, fmt.Sprintf("%g", v))
+			addLabel(Point{Y: v}, theme.TickLabel, theme.TickFont, fmt.Sprintf("%g", v))
+		}
+	}
+	if !showAxes {
+		return
+	}
+
+	// The world axes through the origin
+	for _, a := range axes {
+		end := Point{X: a.dir.X * axisLength, Y: a.dir.Y * axisLength, Z: a.dir.Z * axisLength}
+		addLine(Point{X: -end.X, Y: -end.Y, Z: -end.Z}, end, a.colour, 2)
+		addLabel(end, a.colour, theme.AxisFont, a.name)
+	}
+
+	// The orientation gizmo in the bottom left corner.  Only the rotation is shown, so the scale and translation are
+	// removed by normalising each axis direction
+	gizmoX := left + gizmoLength + 15
+	gizmoY := bottom - gizmoLength - 15
+	origin := transform(sceneMatrix, Point{})
+	type gizmoAxis struct {
+		x, y, z float64
+		colour  string
+		name    string
+	}
+	var g []gizmoAxis
+	for _, a := range axes {
+		t := transform(sceneMatrix, a.dir)
+		dx, dy, dz := t.X-origin.X, t.Y-origin.Y, t.Z-origin.Z
+		l := math.Sqrt(dx*dx + dy*dy + dz*dz)
+		if l == 0 {
+			continue
+		}
+		g = append(g, gizmoAxis{x: dx / l, y: dy / l, z: dz / l, colour: a.colour, name: a.name})
+	}
+
+	// Draw the axes pointing away from the viewer first
+	sort.Slice(g, func(i, j int) bool { return g[i].z < g[j].z })
+	for _, a := range g {
+		x2 := gizmoX + (a.x * gizmoLength)
+		y2 := gizmoY - (a.y * gizmoLength)
+		lines = append(lines, guideLine{x1: gizmoX, y1: gizmoY, x2: x2, y2: y2, colour: a.colour, width: 2})
+		labels = append(labels, guideLabel{x: x2 + 3, y: y2 - 3, colour: a.colour, font: theme.AxisFont, text: a.name})
+	}
+	return
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// An axis aligned bounding box
+type aabb struct {
+	Min, Max Point
+}
+
+// A bounding sphere
+type sphere struct {
+	Centre Point
+	Radius float64
+}
+
+var (
+	showBounds   = false // If true, the bounding boxes and spheres of the objects are drawn
+	fitMargin    = 0.9   // Fraction of the graph area the content fills after zooming to fit
+	fitTime      = int32(600)
+	fitFrames    = int32(30)
+	spherePieces = 48 // Number of straight lines a bounding sphere outline is drawn with
+)
+
+// Returns the bounding box around the given points.  Points with NaN co-ordinates are ignored.  The bool is false when
+// there are no valid points
+func pointsAABB(pts []Point) (box aabb, ok bool) {
+	box.Min = Point{X: math.Inf(1), Y: math.Inf(1), Z: math.Inf(1)}
+	box.Max = Point{X: math.Inf(-1), Y: math.Inf(-1), Z: math.Inf(-1)}
+	for _, p := range pts {
+		if !validPoint(p) {
+			continue
+		}
+		box.Min.X, box.Max.X = math.Min(box.Min.X, p.X), math.Max(box.Max.X, p.X)
+		box.Min.Y, box.Max.Y = math.Min(box.Min.Y, p.Y), math.Max(box.Max.Y, p.Y)
+		box.Min.Z, box.Max.Z = math.Min(box.Min.Z, p.Z), math.Max(box.Max.Z, p.Z)
+		ok = true
+	}
+	return
+}
+
+// Returns the smallest box containing both boxes
+func (a aabb) union(b aabb) aabb {
+	return aabb{
+		Min: Point{X: math.Min(a.Min.X, b.Min.X), Y: math.Min(a.Min.Y, b.Min.Y), Z: math.Min(a.Min.Z, b.Min.Z)},
+		Max: Point{X: math.Max(a.Max.X, b.Max.X), Y: math.Max(a.Max.Y, b.Max.Y), Z: math.Max(a.Max.Z, b.Max.Z)},
+	}
+}
+
+// Returns the centre of the box
+func (a aabb) centre() Point {
+	return Point{X: (a.Min.X + a.Max.X) / 2, Y: (a.Min.Y + a.Max.Y) / 2, Z: (a.Min.Z + a.Max.Z) / 2}
+}
+
+// Returns the eight corners of the box.  The index bits pick the max side for X, then Y, then Z
+func (a aabb) corners() (c [8]Point) {
+	for i := range c {
+		c[i] = a.Min
+		if i&1 != 0 {
+			c[i].X = a.Max.X
+		}
+		if i&2 != 0 {
+			c[i].Y = a.Max.Y
+		}
+		if i&4 != 0 {
+			c[i].Z = a.Max.Z
+		}
+	}
+	return
+}
+
+// Returns a bounding sphere around the given points, using Ritter's algorithm.  This is quick, and usually within a few
+// percent of the smallest possible sphere.  The bool is false when there are no valid points
+func pointsSphere(pts []Point) (s sphere, ok bool) {
+	var valid []Point
+	for _, p := range pts {
+		if validPoint(p) {
+			valid = append(valid, p)
+		}
+	}
+	if len(valid) == 0 {
+		return s, false
+	}
+
+	// Start with the two points furthest apart along a rough search, as the diameter
+	farthest := func(from Point) Point {
+		best, bestD := from, -1.0
+		for _, p := range valid {
+			if d := distance(from, p); d > bestD {
+				best, bestD = p, d
+			}
+		}
+		return best
+	}
+	a := farthest(valid[0])
+	b := farthest(a)
+	s.Centre = Point{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2, Z: (a.Z + b.Z) / 2}
+	s.Radius = distance(a, b) / 2
+
+	// Grow the sphere to take in any points still outside it
+	for _, p := range valid {
+		d := distance(s.Centre, p)
+		if d <= s.Radius {
+			continue
+		}
+		r := (s.Radius + d) / 2
+		f := (r - s.Radius) / d
+		s.Centre = Point{X: s.Centre.X + (p.X-s.Centre.X)*f, Y: s.Centre.Y + (p.Y-s.Centre.Y)*f, Z: s.Centre.Z + (p.Z-s.Centre.Z)*f}
+		s.Radius = r
+	}
+	return s, true
+}
+
+// Returns the distance between two points
+func distance(a Point, b Point) float64 {
+	return math.Sqrt((b.X-a.X)*(b.X-a.X) + (b.Y-a.Y)*(b.Y-a.Y) + (b.Z-a.Z)*(b.Z-a.Z))
+}
+
+// Returns the points of an object in world co-ordinates, before the scene root (view) transform is applied.  Bounds are
+// worked out in these co-ordinates, so the boxes stay lined up with the world axes as the view rotates
+func unviewedPoints(o Object) []Point {
+	inv, ok := invertMatrix(sceneMatrix)
+	if !ok {
+		return nil
+	}
+	pts := make([]Point, len(o.P))
+	for i, p := range o.P {
+		pts[i] = transform(inv, p)
+	}
+	return pts
+}
+
+// Returns the bounding box and sphere of an object, in world co-ordinates
+func objectBounds(o Object) (box aabb, s sphere, ok bool) {
+	pts := unviewedPoints(o)
+	if box, ok = pointsAABB(pts); !ok {
+		return
+	}
+	s, ok = pointsSphere(pts)
+	return
+}
+
+// Returns the bounding box and sphere of everything in the scene, in world co-ordinates
+func sceneBounds(ws map[string]Object) (box aabb, s sphere, ok bool) {
+	var all []Point
+	for _, o := range ws {
+		all = append(all, unviewedPoints(o)...)
+	}
+	if box, ok = pointsAABB(all); !ok {
+		return
+	}
+	s, ok = pointsSphere(all)
+	return
+}
+
+// Returns the lines for drawing the bounding boxes and spheres, projected onto the graph.  When showBounds is on, every
+// object gets its box and sphere, and the whole scene gets a heavier box.  The selected object's box is always drawn
+func boundsGuides(ws map[string]Object, centerX float64, centerY float64, step float64) (lines []guideLine) {
+	project := func(p Point) (float64, float64) {
+		t := transform(sceneMatrix, p)
+		return centerX + (t.X * step), centerY + ((t.Y * step) * -1)
+	}
+	addBox := func(box aabb, colour string, w float64) {
+		c := box.corners()
+		for i := range c {
+			for _, bit := range []int{1, 2, 4} {
+				// Each corner connects to the corners one bit away, only counting each edge once
+				if i&bit != 0 {
+					continue
+				}
+				x1, y1 := project(c[i])
+				x2, y2 := project(c[i|bit])
+				lines = append(lines, guideLine{x1: x1, y1: y1, x2: x2, y2: y2, colour: colour, width: w})
+			}
+		}
+	}
+	addSphere := func(s sphere, colour string) {
+		// An orthographic view of a sphere is always a circle, with the radius scaled by the view
+		x, y := project(s.Centre)
+		o, e := transform(sceneMatrix, Point{}), transform(sceneMatrix, Point{X: 1})
+		r := s.Radius * distance(o, e) * step
+		for i := 0; i < spherePieces; i++ {
+			a1 := 2 * math.Pi * float64(i) / float64(spherePieces)
+			a2 := 2 * math.Pi * float64(i+1) / float64(spherePieces)
+			lines = append(lines, guideLine{x1: x + r*math.Cos(a1), y1: y + r*math.Sin(a1), x2: x + r*math.Cos(a2),
+				y2: y + r*math.Sin(a2), colour: colour, width: 1})
+		}
+	}
+
+	if showBounds {
+		for _, name := range sortedNames(ws) {
+			if box, s, ok := objectBounds(ws[name]); ok {
+				addBox(box, theme.Bounds, 1)
+				addSphere(s, theme.Bounds)
+			}
+		}
+		if box, _, ok := sceneBounds(ws); ok {
+			addBox(box, theme.Bounds, 2)
+		}
+	}
+	if o, ok := ws[selected]; ok {
+		if box, _, ok := objectBounds(o); ok {
+			addBox(box, theme.Selected, 2)
+		}
+	}
+	return
+}
+
+// Zooms and pans the view so the given objects fill the graph area.  With no names, everything in the scene is used
+func fitView(names ...string) {
+	if renderActive.Load() {
+		return
+	}
+	if op, ok := fitOperation(names...); ok {
+		queue <- op
+	}
+}
+
+// Returns the operation fitting the given objects to the graph area, as for fitView.  The second return value is false
+// if there are no points to fit
+func fitOperation(names ...string) (Operation, bool) {
+	if len(names) == 0 {
+		names = sortedNames(worldSpace)
+	}
+
+	// Work out the extents on screen, which are the X and Y of the world space points
+	var pts []Point
+	for _, n := range names {
+		pts = append(pts, worldSpace[n].P...)
+	}
+	box, ok := pointsAABB(pts)
+	if !ok {
+		return Operation{}, false
+	}
+	step := math.Min(width, height) / 30
+	hx, hy := (box.Max.X-box.Min.X)/2, (box.Max.Y-box.Min.Y)/2
+	f := math.Inf(1)
+	if hx > 0 {
+		f = math.Min(f, fitMargin*graphWidth/2/step/hx)
+	}
+	if hy > 0 {
+		f = math.Min(f, fitMargin*graphHeight/2/step/hy)
+	}
+	if math.IsInf(f, 1) {
+		f = 1
+	}
+	c := box.centre()
+	return Operation{op: FIT, t: fitTime, f: fitFrames, X: c.X, Y: c.Y, Z: f}, true
+}
+
+// Describes the extents of an object, for the side panel
+func boundsText(o Object) string {
+	box, s, ok := objectBounds(o)
+	if !ok {
+		return "No points"
+	}
+	return fmt.Sprintf("Size: %0.1f x %0.1f x %0.1f, radius %0.1f", box.Max.X-box.Min.X, box.Max.Y-box.Min.Y,
+		box.Max.Z-box.Min.Z, s.Radius)
+}
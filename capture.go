@@ -0,0 +1,201 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"math"
+	"syscall/js"
+)
+
+type recordFormat int
+
+const (
+	recordPNG recordFormat = iota // Numbered PNG files, downloaded together as a zip
+	recordGIF                     // A single animated GIF
+)
+
+// Captures each frame rendered by the operations processor, while recording is active
+type recorder struct {
+	format    recordFormat
+	fps       int     // The fixed frame rate to record at
+	scale     float64 // Scale factor applied to the graph area when capturing frames
+	count     int     // Number of frames captured so far
+	remaining int     // If non-zero, recording stops automatically once this many more operations have finished
+	zipBuf    bytes.Buffer
+	zipWriter *zip.Writer
+	anim      gif.GIF
+}
+
+var (
+	rec          *recorder // The active recorder.  nil when not recording
+	recordFPS    = 30
+	gifScale     = 0.5 // GIF frames are kept in memory until the end, so they're captured at a smaller size
+	recordPrefix = "wasmGraph"
+)
+
+// Starts a new recording
+func startRecording(format recordFormat) {
+	r := &recorder{format: format, fps: recordFPS, scale: 1}
+	switch format {
+	case recordPNG:
+		r.zipWriter = zip.NewWriter(&r.zipBuf)
+	case recordGIF:
+		r.scale = gifScale
+	}
+	rec = r
+}
+
+// Stops the active recording, and has the browser download the result
+func stopRecording() {
+	r := rec
+	rec = nil
+	if r == nil {
+		return
+	}
+	if r.count == 0 {
+		fmt.Println("Recording stopped, but no frames were captured")
+		return
+	}
+
+	var b bytes.Buffer
+	var err error
+	switch r.format {
+	case recordPNG:
+		err = r.zipWriter.Close()
+		if err == nil {
+			saveBlob(recordPrefix+"-frames.zip", bytesBlob(r.zipBuf.Bytes(), "application/zip"))
+		}
+	case recordGIF:
+		err = gif.EncodeAll(&b, &r.anim)
+		if err == nil {
+			saveBlob(recordPrefix+".gif", bytesBlob(b.Bytes(), "image/gif"))
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error when saving recording: %v\n", err)
+	}
+}
+
+// Starts or stops recording, depending on whether a recording is already active
+func toggleRecording(format recordFormat) {
+	if rec != nil {
+		stopRecording()
+		return
+	}
+	startRecording(format)
+}
+
+// Resets the world space, then records the given operations.  Recording stops by itself once they've all finished
+func recordOperations(ops []Operation, format recordFormat) {
+	if len(ops) == 0 {
+		return
+	}
+	initWorld()
+	startRecording(format)
+	rec.remaining = len(ops)
+	for _, o := range ops {
+		queue <- o
+	}
+}
+
+// Returns the number of frames an operation of the given duration (in milliseconds) needs at the recording frame rate
+func (r *recorder) frameCount(t int32) int32 {
+	return int32(math.Max(1, math.Round(float64(t)*float64(r.fps)/1000)))
+}
+
+// Renders the current state of the world space, then captures the graph area as the next frame
+func (r *recorder) captureFrame() {
+	drawFrame()
+	img := captureGraph(r.scale)
+	r.count++
+
+	switch r.format {
+	case recordPNG:
+		f, err := r.zipWriter.CreateHeader(&zip.FileHeader{
+			Name:   fmt.Sprintf("%s-%05d.png", recordPrefix, r.count),
+			Method: zip.Store, // PNGs are already compressed
+		})
+		if err == nil {
+			err = png.Encode(f, img)
+		}
+		if err != nil {
+			fmt.Printf("Error when recording frame %d: %v\n", r.count, err)
+		}
+	case recordGIF:
+		p := image.NewPaletted(img.Bounds(), palette.WebSafe)
+		draw.Draw(p, p.Rect, img, img.Rect.Min, draw.Src)
+		r.anim.Image = append(r.anim.Image, p)
+		// GIF delays are in 100ths of a second, which don't divide evenly at most frame rates.  So each frame gets the
+		// time up to where it should end, rounded, keeping the total length exact
+		end := func(frame int) int { return int(math.Round(float64(frame) * 100 / float64(r.fps))) }
+		r.anim.Delay = append(r.anim.Delay, end(r.count)-end(r.count-1))
+	}
+}
+
+// Called by the operations processor each time an operation finishes
+func (r *recorder) operationDone() {
+	if r.remaining == 0 {
+		return
+	}
+	r.remaining--
+	if r.remaining == 0 {
+		stopRecording()
+	}
+}
+
+// Saves a PNG screenshot of the graph area
+func screenshotPNG() {
+	var b bytes.Buffer
+	err := png.Encode(&b, captureGraph(1))
+	if err != nil {
+		fmt.Printf("Error when creating PNG screenshot: %v\n", err)
+		return
+	}
+	saveBlob(recordPrefix+".png", bytesBlob(b.Bytes(), "image/png"))
+}
+
+// Copies the pixels of the graph area into a Go image, scaled by the given factor
+func captureGraph(scaleBy float64) *image.RGBA {
+	w := int(graphWidth * scaleBy)
+	h := int(graphHeight * scaleBy)
+	src := ctx
+	if scaleBy != 1 {
+		// Draw the graph area onto a scratch canvas of the desired size
+		c := newCanvas(w, h)
+		src = c.Call("getContext", "2d")
+		src.Call("drawImage", canvasEl, 0, 0, graphWidth, graphHeight, 0, 0, w, h)
+	}
+	imgData := src.Call("getImageData", 0, 0, w, h)
+
+	// Have JS copy the pixel data straight into the Go image's memory
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	ta := js.TypedArrayOf(img.Pix)
+	ta.Call("set", imgData.Get("data"))
+	ta.Release()
+	return img
+}
+
+// Creates a new canvas which isn't part of the page
+func newCanvas(w int, h int) js.Value {
+	if inWorker {
+		return js.Global().Get("OffscreenCanvas").New(w, h)
+	}
+	c := doc.Call("createElement", "canvas")
+	c.Set("width", w)
+	c.Set("height", h)
+	return c
+}
+
+// Returns a new JS Blob holding a copy of the given bytes
+func bytesBlob(b []byte, mimeType string) js.Value {
+	ta := js.TypedArrayOf(b)
+	blob := js.Global().Get("Blob").New([]interface{}{ta}, map[string]interface{}{"type": mimeType})
+	ta.Release()
+	return blob
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+)
+
+// A colour at a position (0 to 1) along a colour map
+type colourStop struct {
+	pos     float64
+	r, g, b float64
+}
+
+// A smooth run of colours, used to turn scalar values into colours.  The stops are in position order
+type colourMap []colourStop
+
+// A colour map stop converted for drawing, as used by canvas and SVG gradients
+type gradientStop struct {
+	pos    float64
+	colour string
+}
+
+// A colour bar for the legend in the side panel
+type colourBar struct {
+	title  string
+	cm     colourMap
+	lo, hi float64
+}
+
+var (
+	// The built in colour maps.  Viridis and plasma are sampled from the matplotlib maps of the same names
+	colourMaps = map[string]colourMap{
+		"viridis":   mustColourMap("#440154, #472d7b, #3b528b, #2c728e, #21918c, #28ae80, #5ec962, #addc30, #fde725"),
+		"plasma":    mustColourMap("#0d0887, #4c02a1, #7e03a8, #a92395, #cc4778, #e56b5d, #f89540, #fdc527, #f0f921"),
+		"diverging": mustColourMap("#3b4cc0, #8db0fe, #dddddd, #f49a7b, #b40426"),
+	}
+	colourMapName     = "viridis" // The colour map given to newly created objects coloured by a scalar
+	colourMapSteps    = 8         // Number of pieces a colour map is split into, when drawn as a gradient
+	colourBarWidth    = 150.0
+	colourBarHeight   = 12.0
+	colourBarMaxCount = 3 // Most colour bars shown in the side panel at once
+
+	// Colour maps given as lists of stops, parsed the first time they're used
+	customColourMaps = map[string]colourMap{}
+)
+
+// Returns the names of the built in colour maps, in alphabetical order
+func colourMapNames() (names []string) {
+	for name := range colourMaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Returns the named colour map.  Anything which isn't the name of a built in map is parsed as a list of custom stops,
+// falling back to viridis if that doesn't work either.  This is called for every object each frame, so the parsed
+// custom maps are kept
+func lookupColourMap(name string) colourMap {
+	if cm, ok := colourMaps[name]; ok {
+		return cm
+	}
+	if cm, ok := customColourMaps[name]; ok {
+		return cm
+	}
+	cm, err := parseColourMap(name)
+	if err != nil {
+		cm = colourMaps["viridis"]
+	}
+	customColourMaps[name] = cm
+	return cm
+}
+
+// Parses a comma separated list of colour stops, each a colour optionally followed by its position from 0 to 1, eg
+// "#00f, #fff 0.3, rgb(255, 0, 0)".  Stops without a position are spread evenly between their neighbours
+func parseColourMap(s string) (colourMap, error) {
+	parts := splitTopLevel(s, ',')
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("a colour map needs at least two colours")
+	}
+	cm := make(colourMap, len(parts))
+	given := make([]bool, len(parts))
+	for i, p := range parts {
+		col, pos := p, ""
+		if j := strings.LastIndexAny(p, " \t"); j != -1 && !strings.HasSuffix(p, ")") {
+			col, pos = strings.TrimSpace(p[:j]), strings.TrimSpace(p[j+1:])
+		}
+		r, g, b, err := parseColour(col)
+		if err != nil {
+			return nil, err
+		}
+		cm[i] = colourStop{r: r, g: g, b: b}
+		if pos != "" {
+			f, err := strconv.ParseFloat(pos, 64)
+			if err != nil || f < 0 || f > 1 {
+				return nil, fmt.Errorf("bad colour stop position '%s'", pos)
+			}
+			cm[i].pos, given[i] = f, true
+		}
+	}
+
+	// The ends default to 0 and 1, and the stops in between are spread out evenly
+	if !given[0] {
+		cm[0].pos, given[0] = 0, true
+	}
+	if last := len(cm) - 1; !given[last] {
+		cm[last].pos, given[last] = 1, true
+	}
+	prev := 0
+	for i := 1; i < len(cm); i++ {
+		if !given[i] {
+			continue
+		}
+		for j := prev + 1; j < i; j++ {
+			cm[j].pos = cm[prev].pos + (cm[i].pos-cm[prev].pos)*float64(j-prev)/float64(i-prev)
+		}
+		if cm[i].pos < cm[prev].pos {
+			return nil, fmt.Errorf("colour stop positions need to go up in order")
+		}
+		prev = i
+	}
+	return cm, nil
+}
+
+// Like parseColourMap, but panics on error.  For the built in maps
+func mustColourMap(s string) colourMap {
+	cm, err := parseColourMap(s)
+	if err != nil {
+		panic(err)
+	}
+	return cm
+}
+
+// Parses a colour given as #rgb, #rrggbb, or rgb(r, g, b), returning its red, green, and blue parts (0 to 255)
+func parseColour(s string) (r float64, g float64, b float64, err error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case strings.HasPrefix(s, "#") && (len(s) == 4 || len(s) == 7):
+		v, err := strconv.ParseUint(s[1:], 16, 32)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("bad colour '%s'", s)
+		}
+		if len(s) == 4 {
+			return float64((v>>8)&0xf) * 17, float64((v>>4)&0xf) * 17, float64(v&0xf) * 17, nil
+		}
+		return float64((v >> 16) & 0xff), float64((v >> 8) & 0xff), float64(v & 0xff), nil
+	case strings.HasPrefix(s, "rgb(") && strings.HasSuffix(s, ")"):
+		parts := strings.Split(s[4:len(s)-1], ",")
+		if len(parts) != 3 {
+			break
+		}
+		var v [3]float64
+		for i, p := range parts {
+			if v[i], err = strconv.ParseFloat(strings.TrimSpace(p), 64); err != nil {
+				return 0, 0, 0, fmt.Errorf("bad colour '%s'", s)
+			}
+		}
+		return v[0], v[1], v[2], nil
+	}
+	return 0, 0, 0, fmt.Errorf("bad colour '%s', use #rgb, #rrggbb, or rgb(r, g, b)", s)
+}
+
+// Returns the CSS colour at the given position (0 to 1) along the colour map
+func (cm colourMap) at(pos float64) string {
+	if math.IsNaN(pos) {
+		return theme.Point
+	}
+	pos = math.Max(cm[0].pos, math.Min(cm[len(cm)-1].pos, pos))
+	i := 1
+	for i < len(cm)-1 && cm[i].pos < pos {
+		i++
+	}
+	a, b := cm[i-1], cm[i]
+	f := 0.0
+	if b.pos > a.pos {
+		f = (pos - a.pos) / (b.pos - a.pos)
+	}
+	return fmt.Sprintf("rgb(%0.0f, %0.0f, %0.0f)", a.r+(b.r-a.r)*f, a.g+(b.g-a.g)*f, a.b+(b.b-a.b)*f)
+}
+
+// Returns the gradient stops for the part of the colour map between the from and to positions
+func (cm colourMap) stops(from float64, to float64) (stops []gradientStop) {
+	for i := 0; i <= colourMapSteps; i++ {
+		f := float64(i) / float64(colourMapSteps)
+		stops = append(stops, gradientStop{pos: f, colour: cm.at(from + (to-from)*f)})
+	}
+	return
+}
+
+// Returns the values of the scalar attribute an object is coloured by, along with their range and the colour map to
+// use.  The bool is false when the object isn't coloured by a scalar
+func objectScalars(o Object) (vals []float64, lo float64, hi float64, cm colourMap, ok bool) {
+	if o.ColourBy == "" {
+		return
+	}
+	vals, ok = o.Scalars[o.ColourBy]
+	if !ok || len(vals) != len(o.P) {
+		return nil, 0, 0, nil, false
+	}
+	lo, hi = math.Inf(1), math.Inf(-1)
+	for _, v := range vals {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	if lo > hi {
+		lo, hi = 0, 0
+	}
+	return vals, lo, hi, lookupColourMap(o.ColourMap), true
+}
+
+// Returns the colour of each point of an object coloured by a scalar, or nil when it isn't
+func scalarPointColours(o Object) []string {
+	vals, lo, hi, cm, ok := objectScalars(o)
+	if !ok {
+		return nil
+	}
+	cols := make([]string, len(vals))
+	for i, v := range vals {
+		cols[i] = cm.at(normalise(v, lo, hi))
+	}
+	return cols
+}
+
+// Works out a linear gradient which colours a surface by the scalar values at its points.  A plane is fitted to the
+// values over the X and Y co-ordinates (a perfect fit for triangles), and the gradient runs along its slope.  The
+// start and end of the gradient are in world X and Y co-ordinates.  When the values don't change across the surface,
+// the gradient has a single stop
+func surfaceGradient(o Object, s Surface, vals []float64, lo float64, hi float64, cm colourMap) (x1 float64, y1 float64, x2 float64, y2 float64, stops []gradientStop) {
+	// Least squares fit of v = a*x + b*y + c, around the centre of the surface points
+	var cx, cy, cv float64
+	for _, i := range s {
+		cx += o.P[i].X
+		cy += o.P[i].Y
+		cv += vals[i]
+	}
+	n := float64(len(s))
+	cx, cy, cv = cx/n, cy/n, cv/n
+	var sxx, sxy, syy, sxv, syv float64
+	vLo, vHi := math.Inf(1), math.Inf(-1)
+	for _, i := range s {
+		dx, dy, dv := o.P[i].X-cx, o.P[i].Y-cy, vals[i]-cv
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+		sxv += dx * dv
+		syv += dy * dv
+		vLo, vHi = math.Min(vLo, vals[i]), math.Max(vHi, vals[i])
+	}
+	det := sxx*syy - sxy*sxy
+	if math.IsNaN(cv) || math.IsInf(cv, 0) {
+		return cx, cy, cx, cy, []gradientStop{{0, cm.at(math.NaN())}}
+	}
+	if math.Abs(det) < 1e-12 || vHi-vLo < 1e-12 {
+		return cx, cy, cx, cy, []gradientStop{{0, cm.at(normalise(cv, lo, hi))}}
+	}
+	a := (sxv*syy - syv*sxy) / det
+	b := (syv*sxx - sxv*sxy) / det
+	g2 := a*a + b*b
+	if g2 < 1e-12 {
+		return cx, cy, cx, cy, []gradientStop{{0, cm.at(normalise(cv, lo, hi))}}
+	}
+
+	// The gradient runs from where the plane reaches the lowest value at the points, to where it reaches the highest
+	x1, y1 = cx+a*(vLo-cv)/g2, cy+b*(vLo-cv)/g2
+	x2, y2 = cx+a*(vHi-cv)/g2, cy+b*(vHi-cv)/g2
+	return x1, y1, x2, y2, cm.stops(normalise(vLo, lo, hi), normalise(vHi, lo, hi))
+}
+
+// Returns the colour bars for the objects coloured by a scalar, in the same order as the inspector
+func colourBars(ws map[string]Object) (bars []colourBar) {
+	for _, name := range sortedNames(ws) {
+		o := ws[name]
+		if _, lo, hi, cm, ok := objectScalars(o); ok {
+			bars = append(bars, colourBar{title: fmt.Sprintf("%s: %s", name, o.ColourBy), cm: cm, lo: lo, hi: hi})
+			if len(bars) == colourBarMaxCount {
+				break
+			}
+		}
+	}
+	return
+}
+
+// Draws the colour bar legends in the side panel, starting at the given height.  Returns the height below them
+func drawColourBars(textY float64) float64 {
+	for _, bar := range colourBars(worldSpace) {
+		textY += 20
+		ctx.Set("fillStyle", theme.Heading)
+		ctx.Set("font", theme.HeadingFont)
+		ctx.Call("fillText", bar.title, graphWidth+20, textY)
+		textY += 6
+		grad := ctx.Call("createLinearGradient", graphWidth+20, 0, graphWidth+20+colourBarWidth, 0)
+		for _, s := range bar.cm.stops(0, 1) {
+			grad.Call("addColorStop", s.pos, s.colour)
+		}
+		ctx.Set("fillStyle", grad)
+		ctx.Call("fillRect", graphWidth+20, textY, colourBarWidth, colourBarHeight)
+		ctx.Set("strokeStyle", theme.FieldBorder)
+		ctx.Set("lineWidth", "1")
+		ctx.Call("strokeRect", graphWidth+20, textY, colourBarWidth, colourBarHeight)
+		textY += colourBarHeight + 14
+		ctx.Set("fillStyle", theme.Text)
+		ctx.Set("font", theme.TextFont)
+		ctx.Set("textAlign", "left")
+		ctx.Call("fillText", fmt.Sprintf("%.3g", bar.lo), graphWidth+20, textY)
+		ctx.Set("textAlign", "right")
+		ctx.Call("fillText", fmt.Sprintf("%.3g", bar.hi), graphWidth+20+colourBarWidth, textY)
+		ctx.Set("textAlign", "left")
+	}
+	return textY
+}
+
+// Switches every object coloured by a scalar to the next built in colour map
+func cycleColourMap() {
+	names := colourMapNames()
+	next := names[0]
+	for i, n := range names {
+		if n == colourMapName {
+			next = names[(i+1)%len(names)]
+		}
+	}
+	colourMapName = next
+	for _, m := range meshes {
+		if m.ColourBy != "" {
+			m.ColourMap = next
+		}
+	}
+	updateWorldSpace()
+	opText = fmt.Sprintf("Colour map: %s", next)
+}
+
+// Picks up a default colour map from the "colourmap" URL parameter.  This can be the name of a built in map, or a list
+// of custom stops
+func initColourMap() {
+	search := js.Global().Get("location").Get("search").String()
+	params, err := url.ParseQuery(strings.TrimPrefix(search, "?"))
+	if err != nil || params.Get("colourmap") == "" {
+		return
+	}
+	name := params.Get("colourmap")
+	if _, ok := colourMaps[name]; !ok {
+		if _, err := parseColourMap(name); err != nil {
+			fmt.Printf("Error when parsing colour map '%v': %v\n", name, err)
+			return
+		}
+	}
+	colourMapName = name
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseColour(t *testing.T) {
+	tests := []struct {
+		s       string
+		r, g, b float64
+		wantErr bool
+	}{
+		{"#f00", 255, 0, 0, false},
+		{"#00ff80", 0, 255, 128, false},
+		{" #FFF ", 255, 255, 255, false},
+		{"rgb(1, 2.5, 3)", 1, 2.5, 3, false},
+		{"RGB(10,20,30)", 10, 20, 30, false},
+		{"#ff", 0, 0, 0, true},
+		{"#gggggg", 0, 0, 0, true},
+		{"rgb(1, 2)", 0, 0, 0, true},
+		{"rgb(a, b, c)", 0, 0, 0, true},
+		{"red", 0, 0, 0, true},
+		{"", 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		r, g, b, err := parseColour(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v", tt.s, err)
+			continue
+		}
+		if r != tt.r || g != tt.g || b != tt.b {
+			t.Errorf("%q: got %v, %v, %v, wanted %v, %v, %v", tt.s, r, g, b, tt.r, tt.g, tt.b)
+		}
+	}
+}
+
+func TestParseColourMap(t *testing.T) {
+	tests := []struct {
+		s       string
+		pos     []float64 // Positions of the stops
+		wantErr bool
+	}{
+		{"#000, #fff", []float64{0, 1}, false},
+		{"#000, #f00 0.7, #ff0", []float64{0, 0.7, 1}, false},
+		{"#000 0.1, #111, #222, #fff 0.7", []float64{0.1, 0.3, 0.5, 0.7}, false},
+		{"rgb(0, 0, 255), rgb(255, 255, 255) 1", []float64{0, 1}, false},
+		{"#000", nil, true},
+		{"#000, nope", nil, true},
+		{"#000 2, #fff", nil, true},
+		{"#000 x, #fff", nil, true},
+		{"#000, #fff 0.5, #888 0.2", nil, true},
+	}
+	for _, tt := range tests {
+		cm, err := parseColourMap(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v", tt.s, err)
+			continue
+		}
+		var pos []float64
+		for _, st := range cm {
+			pos = append(pos, st.pos)
+		}
+		if len(pos) != len(tt.pos) {
+			t.Errorf("%q: got stops at %v, wanted %v", tt.s, pos, tt.pos)
+			continue
+		}
+		for i := range pos {
+			if d := pos[i] - tt.pos[i]; d > 1e-9 || d < -1e-9 {
+				t.Errorf("%q: got stops at %v, wanted %v", tt.s, pos, tt.pos)
+				break
+			}
+		}
+	}
+}
+
+func TestLookupColourMap(t *testing.T) {
+	if cm := lookupColourMap("plasma"); !reflect.DeepEqual(cm, colourMaps["plasma"]) {
+		t.Error("the built in plasma map wasn't returned")
+	}
+	if cm := lookupColourMap("#000, #fff"); len(cm) != 2 || cm[1].r != 255 {
+		t.Errorf("got %v for a custom map", cm)
+	}
+	if _, ok := customColourMaps["#000, #fff"]; !ok {
+		t.Error("the custom map wasn't kept")
+	}
+	if cm := lookupColourMap("#000"); !reflect.DeepEqual(cm, colourMaps["viridis"]) {
+		t.Error("a bad custom map didn't fall back to viridis")
+	}
+}
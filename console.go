@@ -0,0 +1,575 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+	"time"
+)
+
+// A line of console output
+type consoleLine struct {
+	text  string
+	error bool
+}
+
+var (
+	consoleOutput   []consoleLine // The most recent console output, oldest first
+	consoleHistory  []string      // Commands entered so far, oldest first
+	historyPos      int           // Position in the history when stepping through it with the arrow keys
+	consoleMaxLines = 8           // Number of lines of console output kept and shown
+	consoleTime     = 1000.0      // Default time operations from the console take, in milliseconds
+	consoleKeyCall  js.Callback
+)
+
+// The console commands, with their usage for the help command
+var consoleCommands = []struct {
+	name  string
+	usage string
+}{
+	{"add", "add <primitive> [as <name>] [at x y z]"},
+	{"clear", "clear (the console output)"},
+	{"difference", "difference <object> <object> [keep]"},
+	{"fit", "fit [object ...]"},
+	{"help", "help"},
+	{"hull", "hull <object>"},
+	{"intersect", "intersect <object> <object> [keep]"},
+	{"list", "list"},
+	{"move", "move <object|*> x y z [time]"},
+	{"remove", "remove <object>"},
+	{"reset", "reset"},
+	{"rotate", "rotate <object|*> x|y|z <degrees> [time]"},
+	{"scale", "scale <object|*> <factor> | x y z [time]"},
+	{"select", "select <object>"},
+	{"smooth", "smooth <object> [passes] [factor]"},
+	{"subdivide", "subdivide <object> loop|cc"},
+	{"union", "union <object> <object> [keep]"},
+}
+
+// Adds lines to the console output, dropping the oldest ones once there are too many
+func consolePrint(isError bool, lines ...string) {
+	for _, l := range lines {
+		consoleOutput = append(consoleOutput, consoleLine{text: l, error: isError})
+	}
+	if len(consoleOutput) > consoleMaxLines {
+		consoleOutput = consoleOutput[len(consoleOutput)-consoleMaxLines:]
+	}
+}
+
+// Splits a command into words.  Words can be put in double quotes, for object names with spaces in them
+func splitCommand(line string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	inQuote, inWord := false, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			inWord = true
+		case (r == ' ' || r == '\t') && !inQuote:
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inQuote {
+		return nil, errors.New("missing closing quote")
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
+
+// Returns a name ready for typing into the console, quoting it if it has spaces
+func quoteName(name string) string {
+	if strings.ContainsAny(name, " \t") {
+		return `"` + name + `"`
+	}
+	return name
+}
+
+// Returns the scene node for an object named in a command.  * stands for the whole scene
+func consoleTarget(name string) (string, error) {
+	if name == "*" {
+		return "", nil
+	}
+	if n := findNode(sceneRoot, name); n == nil || n == sceneRoot {
+		return "", fmt.Errorf("unknown object: %v", name)
+	}
+	return name, nil
+}
+
+// Parses a number from a command, naming what it's for in any error
+func consoleNumber(word string, what string) (float64, error) {
+	v, err := strconv.ParseFloat(word, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("%v should be a number, not '%v'", what, word)
+	}
+	return v, nil
+}
+
+// Parses an operation time, like 500ms or 2s.  Plain numbers are milliseconds
+func consoleDuration(word string) (float64, error) {
+	if v, err := strconv.ParseFloat(word, 64); err == nil && v >= 0 {
+		return v, nil
+	}
+	d, err := time.ParseDuration(word)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("time should be like 500ms or 2s, not '%v'", word)
+	}
+	return float64(d) / float64(time.Millisecond), nil
+}
+
+// Queues an operation from the console, taking the given number of milliseconds
+func consoleQueue(op Operation, ms float64) error {
+	if renderActive.Load() {
+		return errors.New("an operation is already in progress, try again when it's finished")
+	}
+	op.t = int32(ms)
+	op.f = int32(math.Max(1, math.Round(ms*60/1000)))
+	queue <- op
+	return nil
+}
+
+// Runs a console command, returning any output.  Errors are returned for bad commands, with a message saying what's
+// wrong
+func runCommand(line string) ([]string, error) {
+	words, err := splitCommand(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, nil
+	}
+	cmd, args := strings.ToLower(words[0]), words[1:]
+	usage := func() error {
+		for _, c := range consoleCommands {
+			if c.name == cmd {
+				return fmt.Errorf("usage: %v", c.usage)
+			}
+		}
+		return nil
+	}
+
+	// Most operations take an optional time as their last word
+	opTime := func(extra []string) (float64, error) {
+		if len(extra) == 0 {
+			return consoleTime, nil
+		}
+		if len(extra) > 1 {
+			return 0, usage()
+		}
+		return consoleDuration(extra[0])
+	}
+
+	switch cmd {
+	case "help":
+		var out []string
+		for _, c := range consoleCommands {
+			out = append(out, c.usage)
+		}
+		return out, nil
+
+	case "clear":
+		consoleOutput = nil
+		return nil, nil
+
+	case "list":
+		var out []string
+		var walk func(n *Node, depth int)
+		walk = func(n *Node, depth int) {
+			if n != sceneRoot {
+				desc := "group"
+				if n.Mesh != nil {
+					desc = fmt.Sprintf("%v, %d points, %d surfaces", n.Mesh.Name, len(n.Mesh.P), len(n.Mesh.S))
+				}
+				out = append(out, fmt.Sprintf("%v%v (%v) at %.2f, %.2f, %.2f", strings.Repeat("  ", depth-1), n.Name,
+					desc, n.Local[3], n.Local[7], n.Local[11]))
+			}
+			for _, c := range n.Children {
+				walk(c, depth+1)
+			}
+		}
+		walk(sceneRoot, 0)
+		if len(out) == 0 {
+			return []string{"The scene is empty."}, nil
+		}
+		return out, nil
+
+	case "reset":
+		if renderActive.Load() {
+			return nil, errors.New("an operation is in progress, try again when it's finished")
+		}
+		initWorld()
+		selected, previous = "", ""
+		measurements, measurePicks, editSelection = nil, nil, nil
+		return []string{"Scene reset."}, nil
+
+	case "rotate":
+		if len(args) < 3 {
+			return nil, usage()
+		}
+		target, err := consoleTarget(args[0])
+		if err != nil {
+			return nil, err
+		}
+		deg, err := consoleNumber(args[2], "the angle")
+		if err != nil {
+			return nil, err
+		}
+		op := Operation{op: ROTATE, target: target}
+		switch strings.ToLower(args[1]) {
+		case "x":
+			op.X = deg
+		case "y":
+			op.Y = deg
+		case "z":
+			op.Z = deg
+		default:
+			return nil, fmt.Errorf("the axis should be x, y, or z, not '%v'", args[1])
+		}
+		ms, err := opTime(args[3:])
+		if err != nil {
+			return nil, err
+		}
+		return nil, consoleQueue(op, ms)
+
+	case "scale", "move":
+		if len(args) < 2 {
+			return nil, usage()
+		}
+		target, err := consoleTarget(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		// Either three numbers for X, Y, and Z, or (for scaling) one number for all of them
+		var v [3]float64
+		n := 0
+		for n < 3 && n+1 < len(args) {
+			if _, err := strconv.ParseFloat(args[n+1], 64); err != nil {
+				break
+			}
+			if v[n], err = consoleNumber(args[n+1], "the amount"); err != nil {
+				return nil, err
+			}
+			n++
+		}
+		switch {
+		case n == 3:
+		case (n == 1 || n == 2) && cmd == "scale":
+			// A single factor for all of them.  When two numbers follow, the second is the time, as with rotate
+			n = 1
+			v[1], v[2] = v[0], v[0]
+		default:
+			return nil, usage()
+		}
+		ms, err := opTime(args[n+1:])
+		if err != nil {
+			return nil, err
+		}
+		op := Operation{op: TRANSLATE, target: target, X: v[0], Y: v[1], Z: v[2]}
+		if cmd == "scale" {
+			if v[0] <= 0 || v[1] <= 0 || v[2] <= 0 {
+				return nil, errors.New("scale factors should be greater than zero")
+			}
+			op.op = SCALE
+		}
+		return nil, consoleQueue(op, ms)
+
+	case "add":
+		if len(args) < 1 {
+			return nil, usage()
+		}
+		gen, ok := primitives[strings.ToLower(args[0])]
+		if !ok {
+			return nil, fmt.Errorf("unknown primitive '%v', try one of: %v", args[0], strings.Join(primitiveNames(), ", "))
+		}
+		name := strings.ToLower(args[0])
+		var at [3]float64
+		rest := args[1:]
+		for len(rest) > 0 {
+			switch strings.ToLower(rest[0]) {
+			case "as":
+				if len(rest) < 2 {
+					return nil, usage()
+				}
+				name, rest = rest[1], rest[2:]
+			case "at":
+				if len(rest) < 4 {
+					return nil, usage()
+				}
+				for i := range at {
+					if at[i], err = consoleNumber(rest[i+1], "the position"); err != nil {
+						return nil, err
+					}
+				}
+				rest = rest[4:]
+			default:
+				return nil, usage()
+			}
+		}
+		name = uniqueMeshName(name)
+		addNode(sceneRoot, name, newMesh(name, gen()), at[0], at[1], at[2])
+		updateWorldSpace()
+		return []string{fmt.Sprintf("Added %v.", quoteName(name))}, nil
+
+	case "remove":
+		if len(args) != 1 {
+			return nil, usage()
+		}
+		n := findNode(sceneRoot, args[0])
+		if n == nil || n == sceneRoot {
+			return nil, fmt.Errorf("unknown object: %v", args[0])
+		}
+		if renderActive.Load() {
+			return nil, errors.New("an operation is in progress, try again when it's finished")
+		}
+		deleteNode(n)
+		if selected == n.Name {
+			selected = ""
+		}
+		updateWorldSpace()
+		return []string{fmt.Sprintf("Removed %v.", quoteName(n.Name))}, nil
+
+	case "select":
+		if len(args) != 1 {
+			return nil, usage()
+		}
+		if _, ok := worldSpace[args[0]]; !ok {
+			return nil, fmt.Errorf("unknown object: %v", args[0])
+		}
+		selectObject(args[0])
+		return []string{opText}, nil
+
+	case "fit":
+		for _, a := range args {
+			if _, ok := worldSpace[a]; !ok {
+				return nil, fmt.Errorf("unknown object: %v", a)
+			}
+		}
+		if renderActive.Load() {
+			return nil, errors.New("an operation is already in progress, try again when it's finished")
+		}
+		fitView(args...)
+		return nil, nil
+
+	case "subdivide":
+		if len(args) != 2 {
+			return nil, usage()
+		}
+		if _, err := consoleTarget(args[0]); err != nil || args[0] == "*" {
+			return nil, fmt.Errorf("unknown object: %v", args[0])
+		}
+		op := Operation{op: LOOP, target: args[0]}
+		switch strings.ToLower(args[1]) {
+		case "loop":
+		case "cc", "catmull-clark":
+			op.op = CATMULLCLARK
+		default:
+			return nil, fmt.Errorf("the scheme should be loop or cc, not '%v'", args[1])
+		}
+		return nil, consoleQueue(op, consoleTime)
+
+	case "smooth":
+		if len(args) < 1 || len(args) > 3 {
+			return nil, usage()
+		}
+		if _, err := consoleTarget(args[0]); err != nil || args[0] == "*" {
+			return nil, fmt.Errorf("unknown object: %v", args[0])
+		}
+		op := Operation{op: SMOOTH, target: args[0], X: float64(smoothIterations), Y: smoothFactor}
+		if len(args) > 1 {
+			if op.X, err = consoleNumber(args[1], "the number of passes"); err != nil {
+				return nil, err
+			}
+		}
+		if len(args) > 2 {
+			if op.Y, err = consoleNumber(args[2], "the factor"); err != nil {
+				return nil, err
+			}
+		}
+		return nil, consoleQueue(op, consoleTime)
+
+	case "hull":
+		if len(args) != 1 {
+			return nil, usage()
+		}
+		if renderActive.Load() {
+			return nil, errors.New("an operation is in progress, try again when it's finished")
+		}
+		name, err := addHull(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return []string{fmt.Sprintf("Added %v.", quoteName(name))}, nil
+
+	case "union", "intersect", "difference":
+		if len(args) < 2 || len(args) > 3 || (len(args) == 3 && strings.ToLower(args[2]) != "keep") {
+			return nil, usage()
+		}
+		for _, a := range args[:2] {
+			if _, err := consoleTarget(a); err != nil || a == "*" {
+				return nil, fmt.Errorf("unknown object: %v", a)
+			}
+		}
+		op := Operation{op: UNION, target: args[0], other: args[1], X: 1}
+		if cmd == "intersect" {
+			op.op = INTERSECT
+		} else if cmd == "difference" {
+			op.op = DIFFERENCE
+		}
+		if len(args) == 3 {
+			op.X = 0
+		}
+		return nil, consoleQueue(op, 100)
+	}
+	return nil, fmt.Errorf("unknown command '%v', type help for a list", words[0])
+}
+
+// Returns the line with its last word completed, if it's the start of a command, primitive, or object name.  When
+// there's more than one possibility, the word is completed as far as they agree and they're returned as well
+func completeCommand(line string) (string, []string) {
+	// Find the start of the word being typed, taking any opening quote into account
+	start, inQuote := 0, false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			if inQuote {
+				start = i
+			}
+		case r == ' ' && !inQuote:
+			start = i + 1
+		}
+	}
+	word := strings.TrimPrefix(line[start:], `"`)
+	before, _ := splitCommand(line[:start])
+
+	var options []string
+	switch {
+	case len(before) == 0:
+		for _, c := range consoleCommands {
+			options = append(options, c.name)
+		}
+	case len(before) == 1 && strings.ToLower(before[0]) == "add":
+		options = primitiveNames()
+	default:
+		options = append(sortedNames(worldSpace), "*")
+	}
+	var matches []string
+	for _, o := range options {
+		if strings.HasPrefix(o, word) {
+			matches = append(matches, o)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return line, nil
+	case 1:
+		return line[:start] + quoteName(matches[0]) + " ", nil
+	}
+	sort.Strings(matches)
+	// Shortened a rune at a time, so names with non-ASCII letters aren't cut part way through one
+	common := []rune(matches[0])
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, string(common)) {
+			common = common[:len(common)-1]
+		}
+	}
+	prefix := string(common)
+	if strings.ContainsAny(prefix, " \t") || strings.HasPrefix(line[start:], `"`) {
+		prefix = `"` + prefix
+	}
+	return line[:start] + prefix, matches
+}
+
+// Handles the keys the console input acts on.  Returns what the input should now hold
+func consoleKey(key string, value string) string {
+	switch key {
+	case "Enter":
+		if strings.TrimSpace(value) == "" {
+			return ""
+		}
+		consoleHistory = append(consoleHistory, value)
+		historyPos = len(consoleHistory)
+		consolePrint(false, "> "+value)
+		out, err := runCommand(value)
+		consolePrint(false, out...)
+		if err != nil {
+			consolePrint(true, err.Error())
+		}
+		return ""
+	case "Tab":
+		line, options := completeCommand(value)
+		if len(options) > 0 {
+			consolePrint(false, strings.Join(options, "  "))
+		}
+		return line
+	case "ArrowUp":
+		if historyPos > 0 {
+			historyPos--
+			return consoleHistory[historyPos]
+		}
+	case "ArrowDown":
+		if historyPos < len(consoleHistory)-1 {
+			historyPos++
+			return consoleHistory[historyPos]
+		}
+		historyPos = len(consoleHistory)
+		return ""
+	}
+	return value
+}
+
+// Returns the recent console output as labels, ending just above the given bottom position
+func consoleLabels(left float64, bottom float64) (labels []guideLabel) {
+	y := bottom - float64(len(consoleOutput))*16
+	for _, l := range consoleOutput {
+		colour := theme.Console
+		if l.error {
+			colour = theme.ConsoleError
+		}
+		labels = append(labels, guideLabel{x: left, y: y, colour: colour, font: theme.ConsoleFont, text: l.text})
+		y += 16
+	}
+	return
+}
+
+// Draws the recent console output at the bottom of the graph area, above the console input
+func drawConsole(left float64, bottom float64) {
+	for _, l := range consoleLabels(left, bottom) {
+		ctx.Set("fillStyle", l.colour)
+		ctx.Set("font", l.font)
+		ctx.Call("fillText", l.text, l.x, l.y)
+	}
+}
+
+// Sets up the console input on the page.  When running in a worker, the page passes the console keys over instead
+func initConsoleInput() {
+	if inWorker {
+		return
+	}
+	input := doc.Call("getElementById", "console")
+	if input == js.Null() {
+		return
+	}
+	consoleKeyCall = js.NewCallback(func(args []js.Value) {
+		key := args[0].Get("key").String()
+		switch key {
+		case "Enter", "Tab", "ArrowUp", "ArrowDown":
+			input.Set("value", consoleKey(key, input.Get("value").String()))
+		}
+	})
+	input.Call("addEventListener", "keydown", consoleKeyCall)
+}
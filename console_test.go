@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"go.uber.org/atomic"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{"rotate ob1 y 90", []string{"rotate", "ob1", "y", "90"}, false},
+		{"  move\tob2  1 2 3 ", []string{"move", "ob2", "1", "2", "3"}, false},
+		{`select "ob1 copy"`, []string{"select", "ob1 copy"}, false},
+		{`add cube as "my cube" at 1 2 3`, []string{"add", "cube", "as", "my cube", "at", "1", "2", "3"}, false},
+		{`add cube as ""`, []string{"add", "cube", "as", ""}, false},
+		{"", nil, false},
+		{`select "ob1 copy`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := splitCommand(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error %v, wanted one: %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: words are %q, wanted %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestCompleteCommand(t *testing.T) {
+	initWorld()
+	for _, name := range []string{"café", "cafè"} {
+		addNode(sceneRoot, name, newMesh(name, cube(1, "red")), 0, 0, 0)
+	}
+	updateWorldSpace()
+	tests := []struct {
+		line        string
+		want        string
+		wantOptions []string
+	}{
+		{"ro", "rotate ", nil},
+		{"s", "s", []string{"scale", "select", "smooth", "subdivide"}},
+		{"add cu", "add cube ", nil},
+		{"add c", "add c", []string{"cone", "cube", "cylinder"}},
+		{"select ob2", "select ob2 ", nil},
+		{"select ob", "select ob", []string{"ob1", "ob1 copy", "ob2", "ob3"}},
+		{`select "ob1 c`, `select "ob1 copy" `, nil},
+		{"fit ob1 ob3", "fit ob1 ob3 ", nil},
+		{"select ca", "select caf", []string{"cafè", "café"}},
+		{"zap", "zap", nil},
+	}
+	for _, tt := range tests {
+		got, options := completeCommand(tt.line)
+		if got != tt.want || !reflect.DeepEqual(options, tt.wantOptions) {
+			t.Errorf("%q: completed to %q with options %q, wanted %q with %q", tt.line, got, options, tt.want, tt.wantOptions)
+		}
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	renderActive = atomic.NewBool(false)
+	tests := []struct {
+		line    string
+		want    *Operation // The operation queued, if any
+		wantErr bool
+	}{
+		{"scale * 2", &Operation{op: SCALE, X: 2, Y: 2, Z: 2, t: 1000, f: 60}, false},
+		{"scale ob2 2 250", &Operation{op: SCALE, target: "ob2", X: 2, Y: 2, Z: 2, t: 250, f: 15}, false},
+		{"scale ob2 1 2 3", &Operation{op: SCALE, target: "ob2", X: 1, Y: 2, Z: 3, t: 1000, f: 60}, false},
+		{"rotate ob1 y 90 500ms", &Operation{op: ROTATE, target: "ob1", Y: 90, t: 500, f: 30}, false},
+		{"ROTATE ob1 X -45", &Operation{op: ROTATE, target: "ob1", X: -45, t: 1000, f: 60}, false},
+		{`move "ob1 copy" 1 2 3 2s`, &Operation{op: TRANSLATE, target: "ob1 copy", X: 1, Y: 2, Z: 3, t: 2000, f: 120}, false},
+		{"subdivide ob3 cc", &Operation{op: CATMULLCLARK, target: "ob3", t: 1000, f: 60}, false},
+		{"", nil, false},
+		{"help", nil, false},
+		{"rotate ob1 w 90", nil, true},
+		{"rotate ob1 x ninety", nil, true},
+		{"rotate ob1 x 90 soon", nil, true},
+		{"rotate nothing x 90", nil, true},
+		{"rotate ob1", nil, true},
+		{"scale ob1 0", nil, true},
+		{"scale ob1 -1", nil, true},
+		{"scale * 2 1s extra", nil, true},
+		{"move ob1 1 2", nil, true},
+		{"subdivide * loop", nil, true},
+		{`select "ob1`, nil, true},
+		{"frobnicate", nil, true},
+	}
+	for _, tt := range tests {
+		initWorld()
+		queue = make(chan Operation, 1)
+		_, err := runCommand(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error %v, wanted one: %v", tt.line, err, tt.wantErr)
+		}
+		var got *Operation
+		if len(queue) > 0 {
+			op := <-queue
+			got = &op
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: queued %+v, wanted %+v", tt.line, got, tt.want)
+		}
+	}
+
+	// Nothing is queued while an operation is in progress
+	renderActive.Store(true)
+	queue = make(chan Operation, 1)
+	if _, err := runCommand("rotate ob1 x 90"); err == nil || len(queue) != 0 {
+		t.Error("an operation was queued while another was in progress")
+	}
+	renderActive.Store(false)
+
+	// Removing an object only removes its mesh when nothing else uses it
+	initWorld()
+	for _, name := range []string{"ob2", "ob1"} {
+		if _, err := runCommand("remove " + name); err != nil {
+			t.Fatalf("remove %v: %v", name, err)
+		}
+	}
+	if _, ok := meshes["object2"]; ok {
+		t.Error("the mesh of a removed object was kept")
+	}
+	if _, ok := meshes["object1"]; !ok {
+		t.Error("a mesh still in use was removed")
+	}
+}
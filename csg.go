@@ -0,0 +1,522 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	csgEpsilon      = 1e-5 // How close (in world units) a point needs to be to a plane to count as on it
+	csgFeatureAngle = 1.0  // Feature angle for the results, so the seams between pieces of split surfaces aren't drawn
+)
+
+// A plane through the scene, where n·p = w for the points p on it.  n is of unit length
+type csgPlane struct {
+	n Point
+	w float64
+}
+
+// A convex polygon being worked on by the CSG operations, along with the plane it lies in
+type csgPolygon struct {
+	pts   []Point
+	plane csgPlane
+}
+
+// A node of a binary space partitioning tree.  Polygons in front of the node's plane go down the front branch, and
+// those behind it down the back branch
+type csgNode struct {
+	plane       *csgPlane
+	front, back *csgNode
+	polys       []csgPolygon
+}
+
+// Returns the dot product of two vectors
+func dot(a Point, b Point) float64 {
+	return a.X*b.X + a.Y*b.Y + a.Z*b.Z
+}
+
+// Returns the plane through three points, facing the way they wind counter-clockwise.  The bool is false if the points
+// are in a line
+func planeFrom(a Point, b Point, c Point) (csgPlane, bool) {
+	n := triangleNormal(a, b, c)
+	l := math.Sqrt(dot(n, n))
+	if l == 0 {
+		return csgPlane{}, false
+	}
+	n = Point{X: n.X / l, Y: n.Y / l, Z: n.Z / l}
+	return csgPlane{n: n, w: dot(n, a)}, true
+}
+
+// Returns the plane facing the other way
+func (p csgPlane) flipped() csgPlane {
+	return csgPlane{n: Point{X: -p.n.X, Y: -p.n.Y, Z: -p.n.Z}, w: -p.w}
+}
+
+// Returns the polygon facing the other way
+func (p csgPolygon) flipped() csgPolygon {
+	pts := make([]Point, len(p.pts))
+	for i, q := range p.pts {
+		pts[len(p.pts)-1-i] = q
+	}
+	return csgPolygon{pts: pts, plane: p.plane.flipped()}
+}
+
+// Splits a polygon by a plane.  Pieces in front of or behind the plane go into front or back, while a polygon lying in
+// the plane goes into coFront or coBack depending on which way it faces
+func (p csgPlane) split(poly csgPolygon, coFront *[]csgPolygon, coBack *[]csgPolygon, front *[]csgPolygon, back *[]csgPolygon) {
+	const (
+		coplanar = 0
+		inFront  = 1
+		behind   = 2
+		spanning = 3
+	)
+	kind := 0
+	kinds := make([]int, len(poly.pts))
+	for i, q := range poly.pts {
+		t := dot(p.n, q) - p.w
+		k := coplanar
+		if t < -csgEpsilon {
+			k = behind
+		} else if t > csgEpsilon {
+			k = inFront
+		}
+		kind |= k
+		kinds[i] = k
+	}
+	switch kind {
+	case coplanar:
+		if dot(p.n, poly.plane.n) > 0 {
+			*coFront = append(*coFront, poly)
+		} else {
+			*coBack = append(*coBack, poly)
+		}
+	case inFront:
+		*front = append(*front, poly)
+	case behind:
+		*back = append(*back, poly)
+	case spanning:
+		var f, b []Point
+		for i, q := range poly.pts {
+			j := (i + 1) % len(poly.pts)
+			ki, kj := kinds[i], kinds[j]
+			if ki != behind {
+				f = append(f, q)
+			}
+			if ki != inFront {
+				b = append(b, q)
+			}
+			if ki|kj == spanning {
+				r := poly.pts[j]
+				d := Point{X: r.X - q.X, Y: r.Y - q.Y, Z: r.Z - q.Z}
+				t := (p.w - dot(p.n, q)) / dot(p.n, d)
+				v := Point{X: q.X + d.X*t, Y: q.Y + d.Y*t, Z: q.Z + d.Z*t}
+				f = append(f, v)
+				b = append(b, v)
+			}
+		}
+		if len(f) >= 3 {
+			*front = append(*front, csgPolygon{pts: f, plane: poly.plane})
+		}
+		if len(b) >= 3 {
+			*back = append(*back, csgPolygon{pts: b, plane: poly.plane})
+		}
+	}
+}
+
+// Adds polygons to a BSP tree, splitting them as needed
+func (n *csgNode) build(polys []csgPolygon) {
+	if len(polys) == 0 {
+		return
+	}
+	if n.plane == nil {
+		pl := polys[0].plane
+		n.plane = &pl
+	}
+	var front, back []csgPolygon
+	for _, p := range polys {
+		n.plane.split(p, &n.polys, &n.polys, &front, &back)
+	}
+	if len(front) > 0 {
+		if n.front == nil {
+			n.front = &csgNode{}
+		}
+		n.front.build(front)
+	}
+	if len(back) > 0 {
+		if n.back == nil {
+			n.back = &csgNode{}
+		}
+		n.back.build(back)
+	}
+}
+
+// Turns the solid represented by a BSP tree inside out
+func (n *csgNode) invert() {
+	for i, p := range n.polys {
+		n.polys[i] = p.flipped()
+	}
+	if n.plane != nil {
+		pl := n.plane.flipped()
+		n.plane = &pl
+	}
+	if n.front != nil {
+		n.front.invert()
+	}
+	if n.back != nil {
+		n.back.invert()
+	}
+	n.front, n.back = n.back, n.front
+}
+
+// Returns the parts of the polygons outside the solid represented by a BSP tree
+func (n *csgNode) clipPolygons(polys []csgPolygon) []csgPolygon {
+	if n.plane == nil {
+		return append([]csgPolygon(nil), polys...)
+	}
+	var front, back []csgPolygon
+	for _, p := range polys {
+		n.plane.split(p, &front, &back, &front, &back)
+	}
+	if n.front != nil {
+		front = n.front.clipPolygons(front)
+	}
+	if n.back != nil {
+		back = n.back.clipPolygons(back)
+	} else {
+		back = nil
+	}
+	return append(front, back...)
+}
+
+// Removes the parts of a BSP tree's polygons inside another tree's solid
+func (n *csgNode) clipTo(other *csgNode) {
+	n.polys = other.clipPolygons(n.polys)
+	if n.front != nil {
+		n.front.clipTo(other)
+	}
+	if n.back != nil {
+		n.back.clipTo(other)
+	}
+}
+
+// Returns all of the polygons in a BSP tree
+func (n *csgNode) allPolygons() []csgPolygon {
+	polys := append([]csgPolygon(nil), n.polys...)
+	if n.front != nil {
+		polys = append(polys, n.front.allPolygons()...)
+	}
+	if n.back != nil {
+		polys = append(polys, n.back.allPolygons()...)
+	}
+	return polys
+}
+
+// Returns the signed volume enclosed by an object's surfaces.  Positive when the surfaces are wound counter-clockwise
+// seen from outside, as they should be
+func signedVolume(o Object) (v float64) {
+	for _, t := range triangulateObject(o) {
+		a, b, c := o.P[t.A], o.P[t.B], o.P[t.C]
+		v += dot(a, triangleNormal(Point{}, b, c)) / 6
+	}
+	return
+}
+
+// Returns a copy of an object with its surfaces wound consistently, all facing outwards.  Neighbouring surfaces are
+// turned to match each other, then everything is turned around if the object ends up inside out
+func orientSurfaces(o Object) Object {
+	type use struct {
+		face    int
+		forward bool
+	}
+	uses := make(map[[2]int][]use)
+	for i, s := range o.S {
+		for j, a := range s {
+			b := s[(j+1)%len(s)]
+			uses[edgeKey(a, b)] = append(uses[edgeKey(a, b)], use{face: i, forward: a < b})
+		}
+	}
+	flip := make([]bool, len(o.S))
+	done := make([]bool, len(o.S))
+	for first := range o.S {
+		if done[first] {
+			continue
+		}
+		done[first] = true
+		todo := []int{first}
+		for len(todo) > 0 {
+			f := todo[0]
+			todo = todo[1:]
+			s := o.S[f]
+			for j, a := range s {
+				b := s[(j+1)%len(s)]
+				u := uses[edgeKey(a, b)]
+				if len(u) != 2 {
+					continue
+				}
+				other, mine := u[0], u[1]
+				if other.face == f {
+					other, mine = mine, other
+				}
+				if done[other.face] {
+					continue
+				}
+				// Neighbours should go along their shared edge in opposite directions
+				done[other.face] = true
+				flip[other.face] = (other.forward == mine.forward) != flip[f]
+				todo = append(todo, other.face)
+			}
+		}
+	}
+	r := o
+	r.S = make([]Surface, len(o.S))
+	r.Holes = make(map[int][]Surface, len(o.Holes))
+	for i, s := range o.S {
+		r.S[i] = s
+		r.Holes[i] = o.Holes[i]
+		if flip[i] {
+			r.S[i] = reversed(s)
+			r.Holes[i] = nil
+			for _, h := range o.Holes[i] {
+				r.Holes[i] = append(r.Holes[i], reversed(h))
+			}
+		}
+	}
+	if signedVolume(r) < 0 {
+		for i, s := range r.S {
+			r.S[i] = reversed(s)
+			var holes []Surface
+			for _, h := range r.Holes[i] {
+				holes = append(holes, reversed(h))
+			}
+			r.Holes[i] = holes
+		}
+	}
+	r.T = triangulateObject(r)
+	return r
+}
+
+// Returns a copy of a surface going around the other way
+func reversed(s Surface) Surface {
+	r := make(Surface, len(s))
+	for i, p := range s {
+		r[len(s)-1-i] = p
+	}
+	return r
+}
+
+// Returns the triangles of a closed object as CSG polygons, or an error if it doesn't have a proper inside
+func csgPolygons(name string, o Object) ([]csgPolygon, error) {
+	o = orientSurfaces(o)
+	t := analyseTopology(o)
+	if !t.closed() {
+		return nil, fmt.Errorf("%v isn't a closed solid (%v)", name, t)
+	}
+	var polys []csgPolygon
+	for _, tri := range o.T {
+		a, b, c := o.P[tri.A], o.P[tri.B], o.P[tri.C]
+		if pl, ok := planeFrom(a, b, c); ok {
+			polys = append(polys, csgPolygon{pts: []Point{a, b, c}, plane: pl})
+		}
+	}
+	return polys, nil
+}
+
+// Combines two closed objects, returning a new closed object.  op is UNION, INTERSECT, or DIFFERENCE (a with b cut
+// away).  The result takes its colour and display settings from a
+func csgCombine(op OperationType, aName string, a Object, bName string, b Object) (Object, error) {
+	pa, err := csgPolygons(aName, a)
+	if err != nil {
+		return Object{}, err
+	}
+	pb, err := csgPolygons(bName, b)
+	if err != nil {
+		return Object{}, err
+	}
+	ta, tb := &csgNode{}, &csgNode{}
+	ta.build(pa)
+	tb.build(pb)
+	switch op {
+	case UNION:
+		ta.clipTo(tb)
+		tb.clipTo(ta)
+		tb.invert()
+		tb.clipTo(ta)
+		tb.invert()
+		ta.build(tb.allPolygons())
+	case INTERSECT:
+		ta.invert()
+		tb.clipTo(ta)
+		tb.invert()
+		ta.clipTo(tb)
+		tb.clipTo(ta)
+		ta.build(tb.allPolygons())
+		ta.invert()
+	case DIFFERENCE:
+		ta.invert()
+		ta.clipTo(tb)
+		tb.clipTo(ta)
+		tb.invert()
+		tb.clipTo(ta)
+		tb.invert()
+		ta.build(tb.allPolygons())
+		ta.invert()
+	}
+	r := weldPolygons(ta.allPolygons())
+	r.C = a.C
+	r.HidePoints, r.HideLabels = a.HidePoints, a.HideLabels
+	r.AutoEdges = true
+	r.FeatureAngle = math.Max(csgFeatureAngle, a.FeatureAngle)
+	r.T = triangulateObject(r)
+	r.E = deriveEdges(r)
+	return r, nil
+}
+
+// Turns CSG polygons into an object, joining up points in the same place.  Points which lie along the edge of a
+// neighbouring polygon (where a surface was split on one side of an edge but not the other) are added to that edge
+// too, so the surfaces join up properly
+func weldPolygons(polys []csgPolygon) (o Object) {
+	index := make(map[[3]int64]int)
+	key := func(p Point) [3]int64 {
+		return [3]int64{int64(math.Round(p.X / csgEpsilon)), int64(math.Round(p.Y / csgEpsilon)), int64(math.Round(p.Z / csgEpsilon))}
+	}
+	var faces []Surface
+	for _, poly := range polys {
+		var s Surface
+		for _, p := range poly.pts {
+			k := key(p)
+			i, ok := index[k]
+			if !ok {
+				i = len(o.P)
+				index[k] = i
+				o.P = append(o.P, Point{X: p.X, Y: p.Y, Z: p.Z})
+			}
+			if len(s) == 0 || s[len(s)-1] != i {
+				s = append(s, i)
+			}
+		}
+		if len(s) > 1 && s[0] == s[len(s)-1] {
+			s = s[:len(s)-1]
+		}
+		if len(s) >= 3 {
+			faces = append(faces, s)
+		}
+	}
+
+	// Fill in points lying along the edges of other surfaces
+	for f, s := range faces {
+		var filled Surface
+		for j, a := range s {
+			b := s[(j+1)%len(s)]
+			filled = append(filled, a)
+			pa, pb := o.P[a], o.P[b]
+			d := Point{X: pb.X - pa.X, Y: pb.Y - pa.Y, Z: pb.Z - pa.Z}
+			l2 := dot(d, d)
+			if l2 == 0 {
+				continue
+			}
+			type onEdge struct {
+				idx int
+				t   float64
+			}
+			var between []onEdge
+			for i, p := range o.P {
+				if i == a || i == b {
+					continue
+				}
+				v := Point{X: p.X - pa.X, Y: p.Y - pa.Y, Z: p.Z - pa.Z}
+				t := dot(v, d) / l2
+				if t <= 0 || t >= 1 {
+					continue
+				}
+				off := Point{X: v.X - d.X*t, Y: v.Y - d.Y*t, Z: v.Z - d.Z*t}
+				if dot(off, off) < csgEpsilon*csgEpsilon {
+					between = append(between, onEdge{idx: i, t: t})
+				}
+			}
+			for x := 1; x < len(between); x++ {
+				for y := x; y > 0 && between[y].t < between[y-1].t; y-- {
+					between[y], between[y-1] = between[y-1], between[y]
+				}
+			}
+			for _, e := range between {
+				filled = append(filled, e.idx)
+			}
+		}
+		faces[f] = filled
+	}
+	o.S = faces
+	o.Mid = midPoint(o.P)
+	return
+}
+
+// Works out the result of a CSG operation on two objects in world space, and adds it to the scene as a new object.
+// When replace is true, the two objects are removed (any children they had are kept, in the same place).  Returns the
+// name of the new object
+func applyCSG(op OperationType, aName string, bName string, replace bool) (string, error) {
+	na, nb := findNode(sceneRoot, aName), findNode(sceneRoot, bName)
+	if na == nil || na.Mesh == nil {
+		return "", fmt.Errorf("unknown object: %v", aName)
+	}
+	if nb == nil || nb.Mesh == nil {
+		return "", fmt.Errorf("unknown object: %v", bName)
+	}
+	if na == nb {
+		return "", fmt.Errorf("can't combine %v with itself", aName)
+	}
+	r, err := csgCombine(op, aName, worldSpace[aName], bName, worldSpace[bName])
+	if err != nil {
+		return "", err
+	}
+	if len(r.S) == 0 {
+		return "", fmt.Errorf("the %v of %v and %v is empty", csgNames[op], aName, bName)
+	}
+
+	// The world space points have the view transform applied, which the new node gets from the scene root instead
+	r.P = unviewedPoints(r)
+	name := uniqueMeshName(fmt.Sprintf("%v %v %v", aName, csgSymbols[op], bName))
+	n := addNode(sceneRoot, name, newMesh(name, r), 0, 0, 0)
+	if replace {
+		for _, old := range []*Node{na, nb} {
+			keepChildren(old)
+			deleteNode(old)
+		}
+		if selected == aName || selected == bName {
+			selected = n.Name
+		}
+		previous = ""
+	}
+	updateWorldSpace()
+	return n.Name, nil
+}
+
+// Moves the children of a node up to the scene root, keeping them where they are in world space
+func keepChildren(n *Node) {
+	inv, ok := invertMatrix(sceneRoot.Local)
+	if !ok {
+		return
+	}
+	for _, c := range append([]*Node(nil), n.Children...) {
+		w := worldMatrix(c)
+		removeNode(c)
+		c.Local = matrixMult(inv, w)
+		addChild(sceneRoot, c)
+	}
+}
+
+// The names and symbols of the CSG operations, for messages and naming their results
+var (
+	csgNames   = map[OperationType]string{UNION: "union", INTERSECT: "intersection", DIFFERENCE: "difference"}
+	csgSymbols = map[OperationType]string{UNION: "+", INTERSECT: "&", DIFFERENCE: "-"}
+)
+
+// Queues a CSG operation between the previously selected object and the selected one, replacing them with the result
+func queueCSG(op OperationType) {
+	if selected == "" || previous == "" || previous == selected {
+		opText = "Click on two objects to combine them first."
+		return
+	}
+	if renderActive.Load() {
+		return
+	}
+	queue <- Operation{op: op, target: previous, other: selected, t: 100, f: 1, X: 1}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Returns a copy of an object moved by the given amounts
+func movedObject(o Object, x float64, y float64, z float64) Object {
+	o.P = append([]Point(nil), o.P...)
+	for i := range o.P {
+		o.P[i].X += x
+		o.P[i].Y += y
+		o.P[i].Z += z
+	}
+	return o
+}
+
+func TestCSGCombine(t *testing.T) {
+	a := cube(2, "red")
+	b := movedObject(cube(2, "blue"), 1, 0, 0)
+	c := movedObject(cube(2, "blue"), 1, 1, 1)
+	tests := []struct {
+		name   string
+		op     OperationType
+		a, b   Object
+		volume float64
+	}{
+		{"union", UNION, a, b, 12},
+		{"intersect", INTERSECT, a, b, 4},
+		{"difference", DIFFERENCE, a, b, 4},
+		{"corner union", UNION, a, c, 15},
+		{"corner intersect", INTERSECT, a, c, 1},
+		{"corner difference", DIFFERENCE, a, c, 7},
+	}
+	for _, tt := range tests {
+		r, err := csgCombine(tt.op, "a", tt.a, "b", tt.b)
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		if v := signedVolume(r); math.Abs(v-tt.volume) > 1e-6 {
+			t.Errorf("%v: volume is %v, want %v", tt.name, v, tt.volume)
+		}
+		top := analyseTopology(r)
+		if !top.closed() || top.Euler != 2 {
+			t.Errorf("%v: result isn't a closed surface: %v", tt.name, top)
+		}
+	}
+}
+
+func TestCSGNeedsClosedObjects(t *testing.T) {
+	open := planeGrid(4, 4, 2, 2, "red")
+	if _, err := csgCombine(UNION, "a", cube(2, "red"), "b", open); err == nil {
+		t.Error("combining with an open object should give an error")
+	}
+}
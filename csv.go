@@ -0,0 +1,362 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"syscall/js"
+)
+
+// Which columns of a CSV file hold each part of the scatter plot.  -1 means the file has no such column
+type csvMapping struct {
+	X, Y, Z int
+	Colour  int // Either CSS colours, or numbers which are coloured using a colour map
+	Size    int // Numbers, which are scaled to the dot sizes
+	Name    int // Labels for the points
+}
+
+var (
+	csvFitSize     = 5.0 // Imported data is scaled so its largest side is this long
+	csvMinSize     = 2.0 // Radius in pixels of the dots for the smallest values in the size column
+	csvMaxSize     = 8.0 // Radius in pixels of the dots for the largest values in the size column
+	csvSampleRows  = 20  // Number of rows looked at when guessing which columns are numeric
+	csvColumns     string
+	csvDropCall    js.Callback
+	csvDragCall    js.Callback
+	csvFileCall    js.Callback
+	csvColumnNames = map[string][]string{
+		"x":      {"x"},
+		"y":      {"y"},
+		"z":      {"z"},
+		"colour": {"colour", "color"},
+		"size":   {"size", "radius"},
+		"name":   {"name", "label", "id"},
+	}
+)
+
+// Works out which columns to use from the header row and data rows of a CSV file.  Columns chosen with the "csv" URL
+// parameter (eg "?csv=x:lon,y:lat,z:height,name:city") come first, then columns with the usual names (x, y, z, colour,
+// size, name).  Any of X, Y and Z still missing are taken from the remaining numeric looking columns, in order
+func guessCSVMapping(header []string, data [][]string) (m csvMapping, err error) {
+	m = csvMapping{X: -1, Y: -1, Z: -1, Colour: -1, Size: -1, Name: -1}
+	fields := map[string]*int{"x": &m.X, "y": &m.Y, "z": &m.Z, "colour": &m.Colour, "size": &m.Size, "name": &m.Name}
+	find := func(name string) int {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), name) {
+				return i
+			}
+		}
+		return -1
+	}
+	used := make(map[int]bool)
+
+	// Columns chosen by the user
+	for _, c := range strings.Split(csvColumns, ",") {
+		kv := strings.SplitN(c, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		if key == "color" {
+			key = "colour"
+		}
+		f, ok := fields[key]
+		if !ok {
+			return m, fmt.Errorf("unknown CSV field '%s'", kv[0])
+		}
+		i := find(kv[1])
+		if i == -1 {
+			return m, fmt.Errorf("there's no '%s' column", strings.TrimSpace(kv[1]))
+		}
+		*f = i
+		used[i] = true
+	}
+
+	// Columns with the usual names
+	for key, names := range csvColumnNames {
+		if *fields[key] != -1 {
+			continue
+		}
+		for _, n := range names {
+			if i := find(n); i != -1 && !used[i] {
+				*fields[key] = i
+				used[i] = true
+				break
+			}
+		}
+	}
+
+	// Fill in any missing co-ordinates from the other numeric columns.  A column counts as numeric when most of the
+	// values in its first few rows are numbers, so the odd blank or "n/a" doesn't rule it out
+	sample := data
+	if len(sample) > csvSampleRows {
+		sample = sample[:csvSampleRows]
+	}
+	cols := len(header)
+	for _, row := range sample {
+		cols = maxInt(cols, len(row))
+	}
+	numeric := func(i int) bool {
+		nums, others := 0, 0
+		for _, row := range sample {
+			if i >= len(row) || strings.TrimSpace(row[i]) == "" {
+				continue
+			}
+			if _, err := strconv.ParseFloat(strings.TrimSpace(row[i]), 64); err == nil {
+				nums++
+			} else {
+				others++
+			}
+		}
+		return nums > others
+	}
+	for _, f := range []*int{&m.X, &m.Y, &m.Z} {
+		if *f != -1 {
+			continue
+		}
+		for i := 0; i < cols; i++ {
+			if !used[i] && numeric(i) {
+				*f = i
+				used[i] = true
+				break
+			}
+		}
+	}
+	if m.X == -1 || m.Y == -1 {
+		return m, fmt.Errorf("couldn't find columns to use for X and Y")
+	}
+	return m, nil
+}
+
+// Reads CSV text into rows of fields.  Rows can have different numbers of fields
+func parseCSV(text string) ([][]string, error) {
+	r := csv.NewReader(strings.NewReader(text))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("the file is empty")
+	}
+	return rows, nil
+}
+
+// Splits the rows of a CSV file into the header row naming the columns, and the data rows.  Files whose first row is
+// all numbers don't have a header, so it's nil and every row is data
+func splitCSVHeader(rows [][]string) (header []string, data [][]string) {
+	for _, f := range rows[0] {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(f, 64); err != nil {
+			return rows[0], rows[1:]
+		}
+	}
+	return nil, rows
+}
+
+// Turns CSV data rows into a point only object, using the given columns.  Rows without valid co-ordinates are skipped,
+// and a missing Z column puts every point at Z = 0.  The header (if any) names the scalar for numeric colours
+func importCSV(header []string, data [][]string, m csvMapping) (o Object, err error) {
+	num := func(row []string, i int) (float64, bool) {
+		if i < 0 || i >= len(row) {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(row[i]), 64)
+		return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+	}
+	field := func(row []string, i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var colourVals, sizeVals []float64
+	numericColour := true
+	for _, row := range data {
+		var p Point
+		var ok bool
+		if p.X, ok = num(row, m.X); !ok {
+			continue
+		}
+		if p.Y, ok = num(row, m.Y); !ok {
+			continue
+		}
+		if m.Z != -1 {
+			if p.Z, ok = num(row, m.Z); !ok {
+				continue
+			}
+		}
+		o.P = append(o.P, p)
+		if m.Name != -1 {
+			o.Labels = append(o.Labels, field(row, m.Name))
+		}
+		if m.Colour != -1 {
+			o.PColour = append(o.PColour, field(row, m.Colour))
+			c, ok := num(row, m.Colour)
+			if !ok && field(row, m.Colour) != "" {
+				numericColour = false
+			}
+			colourVals = append(colourVals, c)
+		}
+		if m.Size != -1 {
+			s, _ := num(row, m.Size)
+			sizeVals = append(sizeVals, s)
+		}
+	}
+	if len(o.P) == 0 {
+		return o, fmt.Errorf("none of the rows have valid co-ordinates")
+	}
+
+	// Numeric colour values become a scalar attribute shown with the colour map, other values are used as CSS colours
+	if m.Colour != -1 && numericColour {
+		name := "colour"
+		if m.Colour < len(header) && strings.TrimSpace(header[m.Colour]) != "" {
+			name = strings.TrimSpace(header[m.Colour])
+		}
+		o.Scalars = map[string][]float64{name: colourVals}
+		o.ColourBy = name
+		o.ColourMap = colourMapName
+		o.PColour = nil
+	}
+	if m.Size != -1 {
+		lo, hi := valueRange(sizeVals)
+		for _, v := range sizeVals {
+			o.PSize = append(o.PSize, csvMinSize+(csvMaxSize-csvMinSize)*normalise(v, lo, hi))
+		}
+	}
+	o.C = plotColour
+	o.HideLabels = m.Name == -1 || len(o.P) > 200
+	return o, nil
+}
+
+// Returns the smallest and largest of the given values
+func valueRange(vals []float64) (lo float64, hi float64) {
+	lo, hi = math.Inf(1), math.Inf(-1)
+	for _, v := range vals {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	return
+}
+
+// Returns where a value sits between lo and hi, from 0 to 1.  When lo and hi are the same, everything is in the middle
+func normalise(v float64, lo float64, hi float64) float64 {
+	if hi <= lo {
+		return 0.5
+	}
+	return math.Max(0, math.Min(1, (v-lo)/(hi-lo)))
+}
+
+// Returns a transform which centres the points on the origin and scales them so the largest side of their bounding box
+// is the given size
+func fitMatrix(pts []Point, size float64) matrix {
+	lo := Point{X: math.Inf(1), Y: math.Inf(1), Z: math.Inf(1)}
+	hi := Point{X: math.Inf(-1), Y: math.Inf(-1), Z: math.Inf(-1)}
+	for _, p := range pts {
+		lo.X, hi.X = math.Min(lo.X, p.X), math.Max(hi.X, p.X)
+		lo.Y, hi.Y = math.Min(lo.Y, p.Y), math.Max(hi.Y, p.Y)
+		lo.Z, hi.Z = math.Min(lo.Z, p.Z), math.Max(hi.Z, p.Z)
+	}
+	side := math.Max(hi.X-lo.X, math.Max(hi.Y-lo.Y, hi.Z-lo.Z))
+	f := 1.0
+	if side > 0 {
+		f = size / side
+	}
+	m := translate(identityMatrix, -(lo.X+hi.X)/2, -(lo.Y+hi.Y)/2, -(lo.Z+hi.Z)/2)
+	return scale(m, f, f, f)
+}
+
+// Loads CSV text as a scatter plot, adding it to the scene as a new node named after the file.  The node is scaled to
+// fit the view, while the mesh keeps the original values
+func loadCSV(fileName string, text string) {
+	rows, err := parseCSV(text)
+	if err != nil {
+		opText = fmt.Sprintf("CSV error: %v", err)
+		return
+	}
+	header, data := splitCSVHeader(rows)
+	if len(data) == 0 {
+		opText = "CSV error: the file has no data rows"
+		return
+	}
+	m, err := guessCSVMapping(header, data)
+	if err != nil {
+		opText = fmt.Sprintf("CSV error: %v", err)
+		return
+	}
+	o, err := importCSV(header, data, m)
+	if err != nil {
+		opText = fmt.Sprintf("CSV error: %v", err)
+		return
+	}
+
+	// Name the node after the file, adding a number if that name is already used
+	base := strings.TrimSuffix(path.Base(fileName), path.Ext(fileName))
+	if base == "" || base == "." || base == "/" {
+		base = "csv"
+	}
+	name := uniqueMeshName(base)
+	n := addNode(sceneRoot, name, newMesh(name, o), 0, 0, 0)
+	n.Local = fitMatrix(o.P, csvFitSize)
+	if len(o.P) > 20 {
+		inspectorCollapsed[name] = true
+	}
+	updateWorldSpace()
+	opText = fmt.Sprintf("Loaded %d points from %v", len(o.P), fileName)
+}
+
+// Reads a File object chosen or dropped by the user, then loads it as CSV
+func readCSVFile(file js.Value) {
+	var loadCall js.Callback
+	name := file.Get("name").String()
+	reader := js.Global().Get("FileReader").New()
+	loadCall = js.NewCallback(func(args []js.Value) {
+		defer loadCall.Release()
+		loadCSV(name, reader.Get("result").String())
+	})
+	reader.Set("onload", loadCall)
+	reader.Call("readAsText", file)
+}
+
+// Sets up loading of CSV files, either with the file picker on the page or by dropping them onto the canvas.  When
+// running in a worker, the page reads the file and sends us its text instead
+func initCSVInput() {
+	search := js.Global().Get("location").Get("search").String()
+	if params, err := url.ParseQuery(strings.TrimPrefix(search, "?")); err == nil {
+		csvColumns = params.Get("csv")
+	}
+	if inWorker {
+		return
+	}
+
+	// Dragging over the canvas needs its default action cancelled, otherwise the browser won't allow drops
+	csvDragCall = js.NewEventCallback(js.PreventDefault, func(event js.Value) {})
+	canvasEl.Call("addEventListener", "dragover", csvDragCall)
+	csvDropCall = js.NewEventCallback(js.PreventDefault, func(event js.Value) {
+		files := event.Get("dataTransfer").Get("files")
+		for i := 0; i < files.Length(); i++ {
+			readCSVFile(files.Index(i))
+		}
+	})
+	canvasEl.Call("addEventListener", "drop", csvDropCall)
+
+	input := doc.Call("getElementById", "csvfile")
+	if input == js.Null() {
+		return
+	}
+	csvFileCall = js.NewCallback(func(args []js.Value) {
+		files := args[0].Get("target").Get("files")
+		for i := 0; i < files.Length(); i++ {
+			readCSVFile(files.Index(i))
+		}
+	})
+	input.Call("addEventListener", "change", csvFileCall)
+}
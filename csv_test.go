@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestGuessCSVMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		columns string // The csv URL parameter
+		want    csvMapping
+		rows    int // Number of data rows
+		wantErr bool
+	}{
+		{"usual names", "name,z,y,x\na,1,2,3\nb,4,5,6", "", csvMapping{X: 3, Y: 2, Z: 1, Colour: -1, Size: -1, Name: 0}, 2, false},
+		{"numeric columns", "city,lon,lat,height\nParis,2.35,48.85,35\nRome,12.5,41.9,21", "",
+			csvMapping{X: 1, Y: 2, Z: 3, Colour: -1, Size: -1, Name: -1}, 2, false},
+		{"chosen columns", "city,lon,lat,height\nParis,2.35,48.85,35", "x:lat,y:lon,name:city",
+			csvMapping{X: 2, Y: 1, Z: 3, Colour: -1, Size: -1, Name: 0}, 1, false},
+		{"no header", "1,2,3\n4,5,6\n7,8,9", "", csvMapping{X: 0, Y: 1, Z: 2, Colour: -1, Size: -1, Name: -1}, 3, false},
+		{"no header, two columns", "1.5,-2\n3,4e2", "", csvMapping{X: 0, Y: 1, Z: -1, Colour: -1, Size: -1, Name: -1}, 2, false},
+		{"non-numeric first row", "id,a,b,c\nfirst,n/a,2,3\nsecond,4,,6\nthird,7,8,9", "",
+			csvMapping{X: 1, Y: 2, Z: 3, Colour: -1, Size: -1, Name: 0}, 3, false},
+		{"colour and size", "x,y,colour,size\n1,2,red,3", "", csvMapping{X: 0, Y: 1, Z: -1, Colour: 2, Size: 3, Name: -1}, 1, false},
+		{"not enough numbers", "a,b\nfoo,1\nbar,2", "", csvMapping{}, 2, true},
+		{"unknown field", "x,y\n1,2", "w:x", csvMapping{}, 1, true},
+		{"missing column", "x,y\n1,2", "x:lon", csvMapping{}, 1, true},
+	}
+	for _, tt := range tests {
+		csvColumns = tt.columns
+		rows, err := parseCSV(tt.csv)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		header, data := splitCSVHeader(rows)
+		if len(data) != tt.rows {
+			t.Errorf("%v: got %d data rows, wanted %d", tt.name, len(data), tt.rows)
+		}
+		m, err := guessCSVMapping(header, data)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: got error %v", tt.name, err)
+			continue
+		}
+		if err == nil && m != tt.want {
+			t.Errorf("%v: got %+v, wanted %+v", tt.name, m, tt.want)
+		}
+	}
+	csvColumns = ""
+}
+
+func TestImportCSV(t *testing.T) {
+	rows, err := parseCSV("1,2,3\n4,5,6\nbad,7,8\n9,10,11")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, data := splitCSVHeader(rows)
+	m, err := guessCSVMapping(header, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := importCSV(header, data, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.P) != 3 || o.P[0] != (Point{X: 1, Y: 2, Z: 3}) || o.P[2] != (Point{X: 9, Y: 10, Z: 11}) {
+		t.Errorf("got points %v, wanted the three rows with valid co-ordinates", o.P)
+	}
+}
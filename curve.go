@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A sampled parametric curve.  The full set of points is kept, so the curve can be drawn a part at a time
+type curve struct {
+	pts     []Point // The sampled points.  A point with NaN co-ordinates marks a gap in the curve
+	markers bool    // If true, a dot is drawn at each sampled point
+	colour  string
+}
+
+var (
+	curves        map[string]*curve // The parametric curves in the scene, by mesh name
+	curveSegments = 64              // Number of evenly spaced segments the curve is first sampled with
+	curveMaxDepth = 6               // Maximum number of times each of those segments can be split in two
+	curveMaxBend  = 5.0             // Segments bending more than this many degrees are split
+)
+
+// Samples the parametric curve (x(t), y(t), z(t)) from tMin to tMax.  Sampling starts with evenly spaced segments,
+// which are then split in two wherever the curve bends sharply, so curvy parts get more points than straight ones
+func sampleCurve(x expr, y expr, z expr, tMin float64, tMax float64) (pts []Point) {
+	eval := func(t float64) Point {
+		v := exprVars{t: t}
+		p := Point{X: x.eval(&v), Y: y.eval(&v), Z: z.eval(&v)}
+		if !validPoint(p) {
+			return Point{X: math.NaN(), Y: math.NaN(), Z: math.NaN()}
+		}
+		return p
+	}
+	var split func(t0 float64, t1 float64, p0 Point, p1 Point, depth int)
+	split = func(t0 float64, t1 float64, p0 Point, p1 Point, depth int) {
+		tm := (t0 + t1) / 2
+		pm := eval(tm)
+		if depth < curveMaxDepth && (!validPoint(p0) || !validPoint(p1) || bendAngle(p0, pm, p1) > curveMaxBend) {
+			split(t0, tm, p0, pm, depth+1)
+			split(tm, t1, pm, p1, depth+1)
+			return
+		}
+		pts = append(pts, p1)
+	}
+
+	p0 := eval(tMin)
+	pts = append(pts, p0)
+	for i := 1; i <= curveSegments; i++ {
+		t0 := tMin + ((tMax - tMin) * float64(i-1) / float64(curveSegments))
+		t1 := tMin + ((tMax - tMin) * float64(i) / float64(curveSegments))
+		p1 := eval(t1)
+		split(t0, t1, p0, p1, 0)
+		p0 = p1
+	}
+	return
+}
+
+// Returns true if none of the point's co-ordinates are NaN or infinite
+func validPoint(p Point) bool {
+	for _, v := range []float64{p.X, p.Y, p.Z} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the angle in degrees between the segment from a to b, and the segment from b to c.  Straight lines are 0
+func bendAngle(a Point, b Point, c Point) float64 {
+	ux, uy, uz := b.X-a.X, b.Y-a.Y, b.Z-a.Z
+	vx, vy, vz := c.X-b.X, c.Y-b.Y, c.Z-b.Z
+	lu := math.Sqrt(ux*ux + uy*uy + uz*uz)
+	lv := math.Sqrt(vx*vx + vy*vy + vz*vz)
+	if lu == 0 || lv == 0 {
+		return 0
+	}
+	cos := ((ux * vx) + (uy * vy) + (uz * vz)) / (lu * lv)
+	return math.Acos(math.Max(-1, math.Min(1, cos))) * 180 / math.Pi
+}
+
+// Returns a polyline object joining the curve points in order.  Gaps in the curve (NaN points) are left out
+func curveObject(pts []Point, markers bool, colour string) (o Object) {
+	o.C = colour
+	o.HidePoints = !markers
+	o.HideLabels = true
+	prev := -1
+	for _, p := range pts {
+		if !validPoint(p) {
+			prev = -1
+			continue
+		}
+		o.P = append(o.P, p)
+		if prev != -1 {
+			o.E = append(o.E, Edge{prev, len(o.P) - 1})
+		}
+		prev = len(o.P) - 1
+	}
+	return
+}
+
+// Returns the start of a curve, covering the given fraction (0 to 1) of its length.  The last point is placed part
+// way along its segment, so the curve grows smoothly
+func partialCurve(pts []Point, frac float64) []Point {
+	segLen := func(i int) float64 {
+		a, b := pts[i-1], pts[i]
+		if !validPoint(a) || !validPoint(b) {
+			return 0
+		}
+		return math.Sqrt((b.X-a.X)*(b.X-a.X) + (b.Y-a.Y)*(b.Y-a.Y) + (b.Z-a.Z)*(b.Z-a.Z))
+	}
+	var total float64
+	for i := 1; i < len(pts); i++ {
+		total += segLen(i)
+	}
+	if frac >= 1 || total == 0 || len(pts) < 2 {
+		return pts
+	}
+	want := total * frac
+	part := []Point{pts[0]}
+	for i := 1; i < len(pts); i++ {
+		l := segLen(i)
+		if want <= l && l > 0 {
+			f := want / l
+			a, b := pts[i-1], pts[i]
+			return append(part, Point{X: a.X + (b.X-a.X)*f, Y: a.Y + (b.Y-a.Y)*f, Z: a.Z + (b.Z-a.Z)*f})
+		}
+		want -= l
+		part = append(part, pts[i])
+	}
+	return part
+}
+
+// Shows the given fraction of a node's curve.  Called by the operations processor for REVEAL operations
+func revealCurve(n *Node, frac float64) {
+	if n.Mesh == nil {
+		return
+	}
+	c, ok := curves[n.Mesh.Name]
+	if !ok {
+		return
+	}
+	updateMesh(n.Mesh, curveObject(partialCurve(c.pts, frac), c.markers, c.colour))
+}
+
+// Parses and plots a parametric curve, given as "x(t), y(t), z(t)" optionally followed by a range for t such as
+// "; t = 0..10".  The range defaults to 0 to 2 pi.  A trailing "!" turns on markers at the sampled points
+func plotCurveExpression(s string) error {
+	markers := false
+	if strings.HasSuffix(s, "!") {
+		markers = true
+		s = strings.TrimSpace(strings.TrimSuffix(s, "!"))
+	}
+	tMin, tMax := 0.0, 2*math.Pi
+	if i := strings.Index(s, ";"); i != -1 {
+		var err error
+		tMin, tMax, err = parseRange(s[i+1:])
+		if err != nil {
+			return err
+		}
+		s = s[:i]
+	}
+	parts := splitTopLevel(s, ',')
+	if len(parts) != 3 {
+		return fmt.Errorf("a curve needs three expressions (x, y, z), not %d", len(parts))
+	}
+	var e [3]expr
+	for i, p := range parts {
+		var err error
+		e[i], err = parseExprVars(p, "t")
+		if err != nil {
+			return fmt.Errorf("%c: %v", "xyz"[i], err)
+		}
+	}
+
+	c := &curve{pts: sampleCurve(e[0], e[1], e[2], tMin, tMax), markers: markers, colour: plotColour}
+	o := curveObject(c.pts, c.markers, c.colour)
+	n := findNode(sceneRoot, plotName)
+	if n == nil {
+		n = addNode(sceneRoot, plotName, newMesh(plotName, o), 0, 0, 0)
+		inspectorCollapsed[plotName] = true
+	} else {
+		updateMesh(n.Mesh, o)
+	}
+	curves[n.Mesh.Name] = c
+	updateWorldSpace()
+	return nil
+}
+
+// Parses a range like "t = 0..10" or "0..10", where the limits can be expressions such as "2*pi"
+func parseRange(s string) (float64, float64, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, "="); i != -1 {
+		s = s[i+1:]
+	}
+	lim := strings.SplitN(s, "..", 2)
+	if len(lim) != 2 {
+		return 0, 0, fmt.Errorf("ranges look like 't = 0..10'")
+	}
+	var v [2]float64
+	for i, l := range lim {
+		if f, err := strconv.ParseFloat(strings.TrimSpace(l), 64); err == nil {
+			v[i] = f
+			continue
+		}
+		e, err := parseExprVars(l, "")
+		if err != nil {
+			return 0, 0, fmt.Errorf("range: %v", err)
+		}
+		v[i] = e.eval(&exprVars{})
+	}
+	return v[0], v[1], nil
+}
+
+// Splits a string on the given separator, ignoring separators inside brackets
+func splitTopLevel(s string, sep byte) (parts []string) {
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
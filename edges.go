@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+var (
+	featureAngles = []float64{0, 20, 45} // The feature angles the e key steps through
+	featureAngle  = 0.0                  // The feature angle most recently picked with the e key
+)
+
+// Returns the edges of an object worked out from its surfaces.  Every outline edge of the surfaces and their holes is
+// included once.  When the object has a feature angle, edges between two surfaces meeting at less than that many
+// degrees (ie nearly flat) are left out, while open edges and those shared by more than two surfaces are always kept
+func deriveEdges(o Object) []Edge {
+	var loops []Surface
+	for i, s := range o.S {
+		loops = append(loops, s)
+		loops = append(loops, o.Holes[i]...)
+	}
+	edges := edgesFromSurfaces(loops)
+	if o.FeatureAngle <= 0 {
+		return edges
+	}
+
+	// Find the surfaces on each side of every edge, and which way they go along it
+	type side struct {
+		face    int
+		forward bool
+	}
+	sides := make(map[[2]int][]side)
+	normals := make([]Point, len(o.S))
+	for i, s := range o.S {
+		normals[i] = polygonNormal(o.P, s)
+		for j, a := range s {
+			b := s[(j+1)%len(s)]
+			if a != b {
+				sides[edgeKey(a, b)] = append(sides[edgeKey(a, b)], side{face: i, forward: a < b})
+			}
+		}
+	}
+	var kept []Edge
+	for _, e := range edges {
+		sd := sides[edgeKey(e[0], e[1])]
+		if len(sd) != 2 {
+			kept = append(kept, e)
+			continue
+		}
+		n1, n2 := normals[sd[0].face], normals[sd[1].face]
+		angle := angleBetween(n1, n2)
+		if sd[0].forward == sd[1].forward {
+			// The surfaces are wound inconsistently, so one normal points the wrong way
+			angle = 180 - angle
+		}
+		if angle > o.FeatureAngle {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// Returns the angle in degrees between two directions.  Zero length directions count as the same direction
+func angleBetween(a Point, b Point) float64 {
+	la := math.Sqrt(a.X*a.X + a.Y*a.Y + a.Z*a.Z)
+	lb := math.Sqrt(b.X*b.X + b.Y*b.Y + b.Z*b.Z)
+	if la == 0 || lb == 0 {
+		return 0
+	}
+	cos := ((a.X * b.X) + (a.Y * b.Y) + (a.Z * b.Z)) / (la * lb)
+	return math.Acos(math.Max(-1, math.Min(1, cos))) * 180 / math.Pi
+}
+
+// Returns whether two lists of edges join the same points in the same order
+func sameEdges(a []Edge, b []Edge) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if a[i][j] != b[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Steps the feature angle of every mesh with derived edges on to the next one, updating their edges
+func cycleFeatureAngle() {
+	next := featureAngles[0]
+	for i, a := range featureAngles {
+		if a == featureAngle {
+			next = featureAngles[(i+1)%len(featureAngles)]
+		}
+	}
+	featureAngle = next
+	for _, m := range meshes {
+		if !m.AutoEdges {
+			continue
+		}
+		m.FeatureAngle = next
+		e := deriveEdges(m.Object)
+		if sameEdges(e, m.E) {
+			continue
+		}
+
+		// The simplified copies work out their edges with the old angle, so they're made again.  The geometry hasn't
+		// changed though, so it's not marked as changed
+		m.E = e
+		m.LODs, m.lodsDone = nil, false
+	}
+	updateWorldSpace()
+	if next == 0 {
+		opText = "Showing all surface edges."
+		return
+	}
+	opText = fmt.Sprintf("Showing edges sharper than %g degrees.", next)
+}
@@ -0,0 +1,546 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+var (
+	editing       = false       // If true, clicks on the graph edit the objects instead of selecting them
+	editSelection []elementPick // The points, edges, and surfaces picked for editing, in the order they were picked
+	dragging      *elementPick  // The point being dragged, if any
+	editTick      = 5.0         // Size of the markers on picked points, in pixels
+)
+
+// Turns edit mode on or off.  Measuring is turned off while editing, as they both use clicks on the graph
+func toggleEditing() {
+	editing = !editing
+	editSelection = nil
+	dragging = nil
+	if !editing {
+		opText = "Editing off."
+		return
+	}
+	measuring = measureOff
+	measurePicks = nil
+	opText = "Editing: drag points to move them, click on empty space to add one."
+}
+
+// Returns the canvas position as view space X and Y co-ordinates, the reverse of screenPos
+func viewPos(x float64, y float64) (float64, float64) {
+	step := math.Min(width, height) / 30
+	return (x - (graphWidth / 2)) / step, ((graphHeight / 2) - y) / step
+}
+
+// Returns true (and says so) if an operation is in progress, as it may be changing the meshes being edited
+func editBusy() bool {
+	if renderActive.Load() {
+		opText = "Editing: wait for the operation in progress to finish."
+		return true
+	}
+	return false
+}
+
+// Returns the index of a pick in the edit selection, or -1 if it's not there
+func editIndex(p elementPick) int {
+	for i, s := range editSelection {
+		if s == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// Handles a mouse button press on the graph in edit mode.  Clicking a point, edge, or surface picks it (or adds it to
+// the picks when shift is held), and points can then be dragged.  Clicking on empty space adds a new point
+func editMouseDown(x float64, y float64, shift bool) {
+	if editBusy() {
+		return
+	}
+	var p elementPick
+	if name, idx := pickPoint(x, y); name != "" {
+		p = elementPick{object: name, kind: pickedPoint, index: idx}
+	} else if name, idx := pickEdge(x, y); name != "" {
+		p = elementPick{object: name, kind: pickedEdge, index: idx}
+	} else if name, idx := pickSurface(x, y); name != "" {
+		p = elementPick{object: name, kind: pickedSurface, index: idx}
+	} else {
+		addEditPoint(x, y)
+		return
+	}
+
+	if shift {
+		if i := editIndex(p); i >= 0 {
+			editSelection = append(editSelection[:i], editSelection[i+1:]...)
+		} else {
+			editSelection = append(editSelection, p)
+		}
+	} else {
+		editSelection = []elementPick{p}
+		if p.kind == pickedPoint {
+			dragging = &p
+		}
+	}
+	opText = fmt.Sprintf("Editing: %d picked.", len(editSelection))
+}
+
+// Moves the point being dragged to follow the mouse, keeping its distance from the viewer the same
+func editMouseMove(x float64, y float64) {
+	if dragging == nil {
+		return
+	}
+	if editBusy() {
+		dragging = nil
+		return
+	}
+	n := findNode(sceneRoot, dragging.object)
+	o, ok := worldSpace[dragging.object]
+	if n == nil || !ok || dragging.index >= len(o.P) {
+		dragging = nil
+		return
+	}
+	p := o.P[dragging.index]
+	p.X, p.Y = viewPos(x, y)
+	setWorldPoint(n, dragging.index, p)
+	updateWorldSpace()
+}
+
+// Finishes dragging a point
+func editMouseUp() {
+	dragging = nil
+}
+
+// Replaces the geometry of a mesh after an edit.  Unlike updateMesh, the points keep the numbers they already have,
+// as edits can remove points from the middle.  New points (with no number yet) are given the next free numbers
+func editMesh(m *Mesh, ob Object) {
+	for i := range ob.P {
+		if ob.P[i].Num == 0 {
+			ob.P[i].Num = pointCounter
+			pointCounter++
+		}
+	}
+	ob.Mid = midPoint(ob.P)
+	ob.T = triangulateObject(ob)
+	if ob.AutoEdges {
+		ob.E = deriveEdges(ob)
+	}
+	m.Object = ob
+	m.geometryChanged()
+	updateWorldSpace()
+}
+
+// Adds a point at the given canvas position, to the object of the first pick (or the selected object).  It's placed
+// at the depth of the object's middle.  When there's no object to add to, a new one is made
+func addEditPoint(x float64, y float64) {
+	target := selected
+	if len(editSelection) > 0 {
+		target = editSelection[0].object
+	}
+	vx, vy := viewPos(x, y)
+	n := findNode(sceneRoot, target)
+	if n == nil || n.Mesh == nil {
+		// Start a new object, placed directly in the scene root
+		name := uniqueMeshName("sketch")
+		inv, ok := invertMatrix(sceneMatrix)
+		if !ok {
+			return
+		}
+		p := transform(inv, Point{X: vx, Y: vy})
+		addNode(sceneRoot, name, newMesh(name, Object{C: theme.Point, P: []Point{p}}), 0, 0, 0)
+		updateWorldSpace()
+		editSelection = []elementPick{{object: name, kind: pickedPoint, index: 0}}
+		selectObject(name)
+		opText = fmt.Sprintf("Started %v.", name)
+		return
+	}
+
+	inv, ok := invertMatrix(worldMatrix(n))
+	if !ok {
+		return
+	}
+	o := n.Mesh.Object
+	o.P = append(append([]Point(nil), o.P...), transform(inv, Point{X: vx, Y: vy, Z: worldSpace[n.Name].Mid.Z}))
+	o.P[len(o.P)-1].Num = 0
+	o = extendPointData(o, len(o.P)-1)
+	editMesh(n.Mesh, o)
+	p := elementPick{object: n.Name, kind: pickedPoint, index: len(o.P) - 1}
+	editSelection = append(editSelection, p)
+	opText = fmt.Sprintf("Added point %d to %v.", o.P[len(o.P)-1].Num, n.Name)
+}
+
+// Extends the per point details of an object (labels, colours, sizes, and scalars) to cover its points, after points
+// have been added.  The first n points already have details
+func extendPointData(o Object, n int) Object {
+	if len(o.Labels) == n {
+		o.Labels = append(append([]string(nil), o.Labels...), "")
+	}
+	if len(o.PColour) == n {
+		o.PColour = append(append([]string(nil), o.PColour...), theme.Point)
+	}
+	if len(o.PSize) == n {
+		o.PSize = append(append([]float64(nil), o.PSize...), 1)
+	}
+	if o.Scalars != nil {
+		s := make(map[string][]float64, len(o.Scalars))
+		for k, v := range o.Scalars {
+			s[k] = v
+			if len(v) == n {
+				s[k] = append(append([]float64(nil), v...), math.NaN())
+			}
+		}
+		o.Scalars = s
+	}
+	return o
+}
+
+// Returns the points picked for editing, which all need to be in the same object.  The bool is false if they aren't,
+// or anything other than points is picked
+func pickedPoints() (string, []int, bool) {
+	if len(editSelection) == 0 {
+		return "", nil, false
+	}
+	name := editSelection[0].object
+	var idx []int
+	for _, p := range editSelection {
+		if p.kind != pickedPoint || p.object != name {
+			return "", nil, false
+		}
+		idx = append(idx, p.index)
+	}
+	return name, idx, true
+}
+
+// Joins the two picked points with an edge
+func connectEdge() {
+	if editBusy() {
+		return
+	}
+	name, idx, ok := pickedPoints()
+	if !ok || len(idx) != 2 || idx[0] == idx[1] {
+		opText = "Pick two points of the same object (shift-click) to join them."
+		return
+	}
+	n := findNode(sceneRoot, name)
+	if n == nil || n.Mesh == nil {
+		return
+	}
+	o := n.Mesh.Object
+	for _, e := range o.E {
+		if edgeKey(e[0], e[1]) == edgeKey(idx[0], idx[1]) {
+			opText = "Those points are already joined."
+			return
+		}
+	}
+
+	// Edges worked out from the surfaces would replace the new one, so the object keeps its current edges from now on
+	o.AutoEdges = false
+	o.E = append(append([]Edge(nil), o.E...), Edge{idx[0], idx[1]})
+	editMesh(n.Mesh, o)
+	opText = fmt.Sprintf("Joined points %d and %d of %v.", o.P[idx[0]].Num, o.P[idx[1]].Num, name)
+}
+
+// Makes a surface from the picked points, going around them in the order they were picked
+func buildSurface() {
+	if editBusy() {
+		return
+	}
+	name, idx, ok := pickedPoints()
+	distinct := make(map[int]bool)
+	for _, i := range idx {
+		distinct[i] = true
+	}
+	if !ok || len(distinct) < 3 || len(distinct) != len(idx) {
+		opText = "Pick three or more points of the same object (shift-click, in order) to make a surface."
+		return
+	}
+	n := findNode(sceneRoot, name)
+	if n == nil || n.Mesh == nil {
+		return
+	}
+	o := n.Mesh.Object
+	o.S = append(append([]Surface(nil), o.S...), Surface(idx))
+	if !o.AutoEdges {
+		// Outline the new surface, where the edges aren't already there
+		have := make(map[[2]int]bool)
+		for _, e := range o.E {
+			have[edgeKey(e[0], e[1])] = true
+		}
+		o.E = append([]Edge(nil), o.E...)
+		for j, a := range idx {
+			b := idx[(j+1)%len(idx)]
+			if !have[edgeKey(a, b)] {
+				o.E = append(o.E, Edge{a, b})
+			}
+		}
+	}
+	editMesh(n.Mesh, o)
+	editSelection = nil
+	opText = fmt.Sprintf("Added surface %d to %v.", len(o.S)-1, name)
+}
+
+// Deletes the picked points, edges, and surfaces.  Edges and surfaces using deleted points go too (although surfaces
+// with enough points left just lose the deleted corners), and everything after a deleted element is renumbered so the
+// indices stay valid.  This includes the picks of the measurements, and those in progress.  Picks are grouped by
+// mesh rather than object, as objects sharing a mesh share its points
+func deleteEditSelection() {
+	if editBusy() {
+		return
+	}
+	if len(editSelection) == 0 {
+		opText = "Nothing picked to delete."
+		return
+	}
+	count := len(editSelection)
+	byMesh := make(map[*Mesh][]elementPick)
+	for _, p := range editSelection {
+		if n := findNode(sceneRoot, p.object); n != nil && n.Mesh != nil {
+			byMesh[n.Mesh] = append(byMesh[n.Mesh], p)
+		}
+	}
+	for m, picks := range byMesh {
+		points, edges, surfaces := make(map[int]bool), make(map[int]bool), make(map[int]bool)
+		for _, p := range picks {
+			switch p.kind {
+			case pickedPoint:
+				points[p.index] = true
+			case pickedEdge:
+				edges[p.index] = true
+			case pickedSurface:
+				surfaces[p.index] = true
+			}
+		}
+		old := m.Object
+		o, newPoints, newSurfaces := deleteElements(old, points, edges, surfaces)
+		editMesh(m, o)
+		remapPicks(m, old, newPoints, newSurfaces)
+		if len(o.P) == 0 {
+			// Nothing left, so remove the objects using the mesh from the scene.  Anything attached to them stays
+			for _, n := range meshNodes(m) {
+				keepChildren(n)
+				deleteNode(n)
+				if selected == n.Name {
+					selectObject("")
+				}
+			}
+			updateWorldSpace()
+		}
+	}
+	opText = fmt.Sprintf("Deleted %d picked.", count)
+}
+
+// Updates the picks of the edit selection and the measurements after elements of a mesh have been deleted, dropping
+// those whose element has gone.  newPoints and newSurfaces give the new index of each old point and surface, or -1 if
+// it was deleted.  Edges are found again by their points, as the remaining edges may have been worked out afresh.  A
+// measurement with any of its picks gone is dropped altogether
+func remapPicks(m *Mesh, old Object, newPoints []int, newSurfaces []int) {
+	newEdges := make(map[[2]int]int)
+	for i, e := range m.E {
+		newEdges[edgeKey(e[0], e[1])] = i
+	}
+	remap := func(p elementPick) (elementPick, bool) {
+		n := findNode(sceneRoot, p.object)
+		if n == nil || n.Mesh != m {
+			return p, true
+		}
+		switch p.kind {
+		case pickedPoint:
+			if p.index >= len(newPoints) || newPoints[p.index] < 0 {
+				return p, false
+			}
+			p.index = newPoints[p.index]
+		case pickedEdge:
+			if p.index >= len(old.E) {
+				return p, false
+			}
+			a, b := newPoints[old.E[p.index][0]], newPoints[old.E[p.index][1]]
+			i, ok := newEdges[edgeKey(a, b)]
+			if a < 0 || b < 0 || !ok {
+				return p, false
+			}
+			p.index = i
+		case pickedSurface:
+			if p.index >= len(newSurfaces) || newSurfaces[p.index] < 0 {
+				return p, false
+			}
+			p.index = newSurfaces[p.index]
+		}
+		return p, true
+	}
+	remapAll := func(picks []elementPick) (out []elementPick, all bool) {
+		all = true
+		for _, p := range picks {
+			if np, ok := remap(p); ok {
+				out = append(out, np)
+			} else {
+				all = false
+			}
+		}
+		return
+	}
+	editSelection, _ = remapAll(editSelection)
+	measurePicks, _ = remapAll(measurePicks)
+	var kept []measurement
+	for _, ms := range measurements {
+		if picks, all := remapAll(ms.picks); all {
+			ms.picks = picks
+			kept = append(kept, ms)
+		}
+	}
+	measurements = kept
+}
+
+// Returns a copy of an object with the given points, edges, and surfaces removed.  The remaining points keep their
+// numbers, while the indices used by the edges and surfaces are updated to match the new point order.  Also returns
+// the new index of each of the old points and surfaces, or -1 for those removed
+func deleteElements(o Object, points map[int]bool, edges map[int]bool, surfaces map[int]bool) (r Object, newIdx []int, newSurface []int) {
+	newIdx = make([]int, len(o.P))
+	r = o
+	r.P = nil
+	for i, p := range o.P {
+		newIdx[i] = -1
+		if !points[i] {
+			newIdx[i] = len(r.P)
+			r.P = append(r.P, p)
+		}
+	}
+	remap := func(s Surface) Surface {
+		var out Surface
+		for _, i := range s {
+			if newIdx[i] >= 0 {
+				out = append(out, newIdx[i])
+			}
+		}
+		return out
+	}
+
+	// Surfaces, and their holes, lose the deleted corners.  Any left with fewer than three go
+	r.S = nil
+	r.Holes = make(map[int][]Surface)
+	newSurface = make([]int, len(o.S))
+	for i, s := range o.S {
+		ns := remap(s)
+		newSurface[i] = -1
+		if surfaces[i] || len(ns) < 3 {
+			continue
+		}
+		newSurface[i] = len(r.S)
+		for _, h := range o.Holes[i] {
+			if nh := remap(h); len(nh) >= 3 {
+				r.Holes[len(r.S)] = append(r.Holes[len(r.S)], nh)
+			}
+		}
+		r.S = append(r.S, ns)
+	}
+
+	// Deleting an edge worked out from the surfaces means the object keeps its own edges from now on
+	if len(edges) > 0 {
+		r.AutoEdges = false
+	}
+	r.E = nil
+	for i, e := range o.E {
+		if edges[i] || newIdx[e[0]] < 0 || newIdx[e[1]] < 0 {
+			continue
+		}
+		r.E = append(r.E, Edge{newIdx[e[0]], newIdx[e[1]]})
+	}
+
+	// The per point details follow their points
+	keepStrings := func(vals []string) []string {
+		if len(vals) != len(o.P) {
+			return vals
+		}
+		var out []string
+		for i, v := range vals {
+			if !points[i] {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+	keepFloats := func(vals []float64) []float64 {
+		if len(vals) != len(o.P) {
+			return vals
+		}
+		var out []float64
+		for i, v := range vals {
+			if !points[i] {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+	r.Labels = keepStrings(o.Labels)
+	r.PColour = keepStrings(o.PColour)
+	r.PSize = keepFloats(o.PSize)
+	if o.Scalars != nil {
+		r.Scalars = make(map[string][]float64, len(o.Scalars))
+		for k, v := range o.Scalars {
+			r.Scalars[k] = keepFloats(v)
+		}
+	}
+	return
+}
+
+// Handles the keys used in edit mode.  Returns false for keys it doesn't use
+func editKey(key string) bool {
+	switch key {
+	case "j":
+		connectEdge()
+	case "J":
+		buildSurface()
+	case "Delete", "Backspace":
+		deleteEditSelection()
+	case "Escape":
+		editSelection = nil
+		opText = "Editing: nothing picked."
+	default:
+		return false
+	}
+	return true
+}
+
+// Returns the markers for the picked elements, projected onto the graph
+func editGuides(ws map[string]Object, centerX float64, centerY float64, step float64) (lines []guideLine) {
+	if !editing {
+		return
+	}
+	screen := func(o Object, idx int) (float64, float64) {
+		return centerX + (o.P[idx].X * step), centerY - (o.P[idx].Y * step)
+	}
+	addLine := func(x1 float64, y1 float64, x2 float64, y2 float64, w float64) {
+		lines = append(lines, guideLine{x1: x1, y1: y1, x2: x2, y2: y2, colour: theme.Selected, width: w})
+	}
+	for _, p := range editSelection {
+		o, ok := ws[p.object]
+		if !ok {
+			continue
+		}
+		switch p.kind {
+		case pickedPoint:
+			if p.index < len(o.P) {
+				x, y := screen(o, p.index)
+				t := editTick
+				addLine(x-t, y-t, x+t, y-t, 2)
+				addLine(x+t, y-t, x+t, y+t, 2)
+				addLine(x+t, y+t, x-t, y+t, 2)
+				addLine(x-t, y+t, x-t, y-t, 2)
+			}
+		case pickedEdge:
+			if p.index < len(o.E) {
+				x1, y1 := screen(o, o.E[p.index][0])
+				x2, y2 := screen(o, o.E[p.index][1])
+				addLine(x1, y1, x2, y2, 3)
+			}
+		case pickedSurface:
+			if p.index < len(o.S) {
+				s := o.S[p.index]
+				for j, a := range s {
+					x1, y1 := screen(o, a)
+					x2, y2 := screen(o, s[(j+1)%len(s)])
+					addLine(x1, y1, x2, y2, 3)
+				}
+			}
+		}
+	}
+	return
+}
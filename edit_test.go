@@ -0,0 +1,153 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Returns two squares side by side, with a triangular hole in the second one, and details for each point:
+//
+//	0 1 2
+//	3 4 5   (hole 6 7 8 inside 1 2 5 4)
+func editTestObject() Object {
+	return Object{
+		P: []Point{
+			{Num: 1, X: 0, Y: 1}, {Num: 2, X: 1, Y: 1}, {Num: 3, X: 2, Y: 1},
+			{Num: 4, X: 0, Y: 0}, {Num: 5, X: 1, Y: 0}, {Num: 6, X: 2, Y: 0},
+			{Num: 7, X: 1.2, Y: 0.2}, {Num: 8, X: 1.8, Y: 0.2}, {Num: 9, X: 1.5, Y: 0.8},
+		},
+		E:         []Edge{{0, 1}, {1, 2}, {3, 4}, {4, 5}, {0, 3}, {1, 4}, {2, 5}},
+		S:         []Surface{{0, 1, 4, 3}, {1, 2, 5, 4}},
+		Holes:     map[int][]Surface{1: {{6, 7, 8}}},
+		AutoEdges: true,
+		Labels:    []string{"a", "b", "c", "d", "e", "f", "g", "h", "i"},
+		PColour:   []string{"red", "orange", "yellow", "green", "blue", "indigo", "violet", "black", "white"},
+		PSize:     []float64{1, 2, 3, 4, 5, 6, 7, 8, 9},
+		Scalars:   map[string][]float64{"t": {10, 20, 30, 40, 50, 60, 70, 80, 90}},
+	}
+}
+
+// Returns a set of indices
+func indexSet(idx ...int) map[int]bool {
+	s := make(map[int]bool)
+	for _, i := range idx {
+		s[i] = true
+	}
+	return s
+}
+
+func TestDeleteElements(t *testing.T) {
+	tests := []struct {
+		name                           string
+		points, edges, surfaces        []int
+		wantS                          []Surface
+		wantHoles                      map[int][]Surface
+		wantE                          []Edge
+		wantNewPoints, wantNewSurfaces []int
+		wantAutoEdges                  bool
+	}{
+		{"middle point", []int{4}, nil, nil,
+			[]Surface{{0, 1, 3}, {1, 2, 4}}, map[int][]Surface{1: {{5, 6, 7}}},
+			[]Edge{{0, 1}, {1, 2}, {0, 3}, {2, 4}},
+			[]int{0, 1, 2, 3, -1, 4, 5, 6, 7}, []int{0, 1}, true},
+		{"surface with the hole after it", nil, nil, []int{0},
+			[]Surface{{1, 2, 5, 4}}, map[int][]Surface{0: {{6, 7, 8}}},
+			[]Edge{{0, 1}, {1, 2}, {3, 4}, {4, 5}, {0, 3}, {1, 4}, {2, 5}},
+			[]int{0, 1, 2, 3, 4, 5, 6, 7, 8}, []int{-1, 0}, true},
+		{"too few corners left", []int{0, 1}, nil, nil,
+			[]Surface{{0, 3, 2}}, map[int][]Surface{0: {{4, 5, 6}}},
+			[]Edge{{1, 2}, {2, 3}, {0, 3}},
+			[]int{-1, -1, 0, 1, 2, 3, 4, 5, 6}, []int{-1, 0}, true},
+		{"hole point", []int{7}, nil, nil,
+			[]Surface{{0, 1, 4, 3}, {1, 2, 5, 4}}, map[int][]Surface{},
+			[]Edge{{0, 1}, {1, 2}, {3, 4}, {4, 5}, {0, 3}, {1, 4}, {2, 5}},
+			[]int{0, 1, 2, 3, 4, 5, 6, -1, 7}, []int{0, 1}, true},
+		{"edge", nil, []int{1}, nil,
+			[]Surface{{0, 1, 4, 3}, {1, 2, 5, 4}}, map[int][]Surface{1: {{6, 7, 8}}},
+			[]Edge{{0, 1}, {3, 4}, {4, 5}, {0, 3}, {1, 4}, {2, 5}},
+			[]int{0, 1, 2, 3, 4, 5, 6, 7, 8}, []int{0, 1}, false},
+	}
+	for _, tt := range tests {
+		o := editTestObject()
+		r, newPoints, newSurfaces := deleteElements(o, indexSet(tt.points...), indexSet(tt.edges...), indexSet(tt.surfaces...))
+		if !reflect.DeepEqual(r.S, tt.wantS) {
+			t.Errorf("%v: surfaces are %v, wanted %v", tt.name, r.S, tt.wantS)
+		}
+		if !reflect.DeepEqual(r.Holes, tt.wantHoles) {
+			t.Errorf("%v: holes are %v, wanted %v", tt.name, r.Holes, tt.wantHoles)
+		}
+		if !reflect.DeepEqual(r.E, tt.wantE) {
+			t.Errorf("%v: edges are %v, wanted %v", tt.name, r.E, tt.wantE)
+		}
+		if !reflect.DeepEqual(newPoints, tt.wantNewPoints) || !reflect.DeepEqual(newSurfaces, tt.wantNewSurfaces) {
+			t.Errorf("%v: new indices are %v and %v, wanted %v and %v", tt.name, newPoints, newSurfaces, tt.wantNewPoints, tt.wantNewSurfaces)
+		}
+		if r.AutoEdges != tt.wantAutoEdges {
+			t.Errorf("%v: AutoEdges is %v, wanted %v", tt.name, r.AutoEdges, tt.wantAutoEdges)
+		}
+
+		// The points keep their numbers, and their details go with them
+		n := len(r.P)
+		if len(r.Labels) != n || len(r.PColour) != n || len(r.PSize) != n || len(r.Scalars["t"]) != n {
+			t.Errorf("%v: %d points, but the details have %d, %d, %d, and %d entries", tt.name, n, len(r.Labels),
+				len(r.PColour), len(r.PSize), len(r.Scalars["t"]))
+			continue
+		}
+		for i, j := range newPoints {
+			if j < 0 {
+				continue
+			}
+			if r.P[j] != o.P[i] || r.Labels[j] != o.Labels[i] || r.PColour[j] != o.PColour[i] || r.PSize[j] != o.PSize[i] ||
+				r.Scalars["t"][j] != o.Scalars["t"][i] {
+				t.Errorf("%v: point %d became %v %v %v %v %v", tt.name, i, r.P[j], r.Labels[j], r.PColour[j], r.PSize[j],
+					r.Scalars["t"][j])
+			}
+		}
+	}
+}
+
+func TestRemapPicks(t *testing.T) {
+	// Objects a and b share a mesh, which has its middle point deleted, while c has a mesh of its own
+	old := editTestObject()
+	r, newPoints, newSurfaces := deleteElements(old, indexSet(4), nil, nil)
+	m := &Mesh{Name: "squares", Object: r}
+	sceneRoot = &Node{Name: "root"}
+	addChild(sceneRoot, &Node{Name: "a", Mesh: m})
+	addChild(sceneRoot, &Node{Name: "b", Mesh: m})
+	addChild(sceneRoot, &Node{Name: "c", Mesh: &Mesh{Name: "other", Object: old}})
+
+	tests := []struct {
+		name string
+		pick elementPick
+		want []elementPick // nil if the pick should be dropped
+	}{
+		{"point after the deleted one", elementPick{"a", pickedPoint, 5}, []elementPick{{"a", pickedPoint, 4}}},
+		{"point before the deleted one", elementPick{"b", pickedPoint, 3}, []elementPick{{"b", pickedPoint, 3}}},
+		{"deleted point", elementPick{"b", pickedPoint, 4}, nil},
+		{"other mesh", elementPick{"c", pickedPoint, 4}, []elementPick{{"c", pickedPoint, 4}}},
+		{"edge found again by its points", elementPick{"a", pickedEdge, 6}, []elementPick{{"a", pickedEdge, 3}}},
+		{"edge of the deleted point", elementPick{"a", pickedEdge, 3}, nil},
+		{"surface", elementPick{"a", pickedSurface, 1}, []elementPick{{"a", pickedSurface, 1}}},
+	}
+	for _, tt := range tests {
+		editSelection = []elementPick{tt.pick}
+		measurePicks = nil
+		measurements = nil
+		remapPicks(m, old, newPoints, newSurfaces)
+		if !reflect.DeepEqual(editSelection, tt.want) {
+			t.Errorf("%v: picks are %v, wanted %v", tt.name, editSelection, tt.want)
+		}
+	}
+
+	// Measurements are dropped altogether when any of their picks go
+	editSelection = nil
+	measurements = []measurement{
+		{measureDistance, []elementPick{{"a", pickedPoint, 0}, {"a", pickedPoint, 5}}},
+		{measureDistance, []elementPick{{"a", pickedPoint, 0}, {"b", pickedPoint, 4}}},
+	}
+	remapPicks(m, old, newPoints, newSurfaces)
+	want := []measurement{{measureDistance, []elementPick{{"a", pickedPoint, 0}, {"a", pickedPoint, 4}}}}
+	if !reflect.DeepEqual(measurements, want) {
+		t.Errorf("measurements are %v, wanted %v", measurements, want)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"syscall/js"
+)
+
+// Exports the current frame as an SVG document, and has the browser download it
+func exportSVG(withPanel bool) {
+	var b bytes.Buffer
+	err := writeSVG(&b, worldSpace, width, height, withPanel)
+	if err != nil {
+		fmt.Printf("Error when creating SVG export: %v\n", err)
+		return
+	}
+	blob := js.Global().Get("Blob").New([]interface{}{b.String()}, map[string]interface{}{"type": "image/svg+xml"})
+	saveBlob("wasmGraph.svg", blob)
+}
+
+// Has the browser save a Blob as a file download.  When running in a worker there's no document to attach the download
+// link to, so the Blob is passed to the page instead
+func saveBlob(filename string, blob js.Value) {
+	if inWorker {
+		js.Global().Call("postMessage", map[string]interface{}{"type": "download", "filename": filename, "blob": blob})
+		return
+	}
+	url := js.Global().Get("URL").Call("createObjectURL", blob)
+	a := doc.Call("createElement", "a")
+	a.Set("href", url)
+	a.Set("download", filename)
+	doc.Get("body").Call("appendChild", a)
+	a.Call("click")
+	doc.Get("body").Call("removeChild", a)
+	js.Global().Get("URL").Call("revokeObjectURL", url)
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The values of the variables available to expressions
+type exprVars struct {
+	x, y, t float64
+}
+
+// A parsed maths expression, ready to be evaluated
+type expr interface {
+	eval(v *exprVars) float64
+}
+
+type numExpr float64
+
+type varExpr byte // One of 'x', 'y', or 't'
+
+type negExpr struct {
+	e expr
+}
+
+type binExpr struct {
+	op   byte // One of '+', '-', '*', '/', or '^'
+	l, r expr
+}
+
+type callExpr struct {
+	name string
+	fn   func(a []float64) float64
+	args []expr
+}
+
+// The functions available to expressions, along with the number of arguments they take
+var exprFuncs = map[string]struct {
+	n  int
+	fn func(a []float64) float64
+}{
+	"sin":   {1, func(a []float64) float64 { return math.Sin(a[0]) }},
+	"cos":   {1, func(a []float64) float64 { return math.Cos(a[0]) }},
+	"tan":   {1, func(a []float64) float64 { return math.Tan(a[0]) }},
+	"asin":  {1, func(a []float64) float64 { return math.Asin(a[0]) }},
+	"acos":  {1, func(a []float64) float64 { return math.Acos(a[0]) }},
+	"atan":  {1, func(a []float64) float64 { return math.Atan(a[0]) }},
+	"sinh":  {1, func(a []float64) float64 { return math.Sinh(a[0]) }},
+	"cosh":  {1, func(a []float64) float64 { return math.Cosh(a[0]) }},
+	"tanh":  {1, func(a []float64) float64 { return math.Tanh(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"ln":    {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log":   {1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"round": {1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"sign": {1, func(a []float64) float64 {
+		if a[0] < 0 {
+			return -1
+		} else if a[0] > 0 {
+			return 1
+		}
+		return 0
+	}},
+	"min":   {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":   {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"atan2": {2, func(a []float64) float64 { return math.Atan2(a[0], a[1]) }},
+	"mod":   {2, func(a []float64) float64 { return math.Mod(a[0], a[1]) }},
+}
+
+// The named constants available to expressions
+var exprConsts = map[string]float64{
+	"pi":  math.Pi,
+	"e":   math.E,
+	"tau": 2 * math.Pi,
+	"phi": math.Phi,
+}
+
+func (n numExpr) eval(v *exprVars) float64 {
+	return float64(n)
+}
+
+func (n varExpr) eval(v *exprVars) float64 {
+	switch n {
+	case 'x':
+		return v.x
+	case 'y':
+		return v.y
+	}
+	return v.t
+}
+
+func (n negExpr) eval(v *exprVars) float64 {
+	return -n.e.eval(v)
+}
+
+func (n binExpr) eval(v *exprVars) float64 {
+	l, r := n.l.eval(v), n.r.eval(v)
+	switch n.op {
+	case '+':
+		return l + r
+	case '-':
+		return l - r
+	case '*':
+		return l * r
+	case '/':
+		return l / r
+	}
+	return math.Pow(l, r)
+}
+
+func (n callExpr) eval(v *exprVars) float64 {
+	a := make([]float64, len(n.args))
+	for i, e := range n.args {
+		a[i] = e.eval(v)
+	}
+	return n.fn(a)
+}
+
+// Returns true if the expression uses the given variable
+func exprUses(e expr, name byte) bool {
+	switch n := e.(type) {
+	case varExpr:
+		return byte(n) == name
+	case negExpr:
+		return exprUses(n.e, name)
+	case binExpr:
+		return exprUses(n.l, name) || exprUses(n.r, name)
+	case callExpr:
+		for _, a := range n.args {
+			if exprUses(a, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Holds the state of an expression being parsed
+type exprParser struct {
+	s    string
+	pos  int
+	vars string // The variable names allowed in the expression
+}
+
+// Parses a maths expression using the variables x, y, and t.  Supports + - * / and ^ (or **) for powers, brackets,
+// the functions in exprFuncs, and the constants in exprConsts
+func parseExpr(s string) (expr, error) {
+	return parseExprVars(s, "xyt")
+}
+
+// Parses a maths expression, only allowing the given variable names (each a single letter)
+func parseExprVars(s string, vars string) (expr, error) {
+	p := &exprParser{s: s, vars: vars}
+	e, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected '%c' at position %d", p.s[p.pos], p.pos+1)
+	}
+	return e, nil
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.s) && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+// Returns true, and moves past it, if the next thing in the expression is the given string
+func (p *exprParser) accept(tok string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.s[p.pos:], tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+// sum := product (('+' | '-') product)*
+func (p *exprParser) parseSum() (expr, error) {
+	l, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op byte
+		if p.accept("+") {
+			op = '+'
+		} else if p.accept("-") {
+			op = '-'
+		} else {
+			return l, nil
+		}
+		r, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		l = binExpr{op: op, l: l, r: r}
+	}
+}
+
+// product := unary (('*' | '/') unary)*
+func (p *exprParser) parseProduct() (expr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op byte
+		p.skipSpace()
+		if strings.HasPrefix(p.s[p.pos:], "**") {
+			// This is a power, which is handled further down
+			return l, nil
+		}
+		if p.accept("*") {
+			op = '*'
+		} else if p.accept("/") {
+			op = '/'
+		} else {
+			return l, nil
+		}
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = binExpr{op: op, l: l, r: r}
+	}
+}
+
+// unary := ('-' | '+') unary | power
+func (p *exprParser) parseUnary() (expr, error) {
+	if p.accept("-") {
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negExpr{e: e}, nil
+	}
+	if p.accept("+") {
+		return p.parseUnary()
+	}
+	return p.parsePower()
+}
+
+// power := primary (('^' | '**') unary)?  Powers group to the right, so 2^3^2 is 2^(3^2)
+func (p *exprParser) parsePower() (expr, error) {
+	l, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.accept("^") || p.accept("**") {
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return binExpr{op: '^', l: l, r: r}, nil
+	}
+	return l, nil
+}
+
+// primary := number | variable | constant | function '(' args ')' | '(' sum ')'
+func (p *exprParser) parsePrimary() (expr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	start := p.pos
+	c := p.s[p.pos]
+	switch {
+	case c == '(':
+		p.pos++
+		e, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("missing ')' for the '(' at position %d", start+1)
+		}
+		return e, nil
+
+	case c == '.' || (c >= '0' && c <= '9'):
+		for p.pos < len(p.s) && (p.s[p.pos] == '.' || (p.s[p.pos] >= '0' && p.s[p.pos] <= '9')) {
+			p.pos++
+		}
+		// Allow exponents, eg 1.5e3
+		if p.pos < len(p.s) && (p.s[p.pos] == 'e' || p.s[p.pos] == 'E') {
+			end := p.pos + 1
+			if end < len(p.s) && (p.s[end] == '-' || p.s[end] == '+') {
+				end++
+			}
+			if end < len(p.s) && p.s[end] >= '0' && p.s[end] <= '9' {
+				for end < len(p.s) && p.s[end] >= '0' && p.s[end] <= '9' {
+					end++
+				}
+				p.pos = end
+			}
+		}
+		f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number '%s' at position %d", p.s[start:p.pos], start+1)
+		}
+		return numExpr(f), nil
+
+	case unicode.IsLetter(rune(c)):
+		for p.pos < len(p.s) && (unicode.IsLetter(rune(p.s[p.pos])) || unicode.IsDigit(rune(p.s[p.pos]))) {
+			p.pos++
+		}
+		name := strings.ToLower(p.s[start:p.pos])
+		if f, ok := exprFuncs[name]; ok {
+			return p.parseCall(name, f.n, f.fn, start)
+		}
+		if v, ok := exprConsts[name]; ok {
+			return numExpr(v), nil
+		}
+		if len(name) == 1 && strings.Contains(p.vars, name) {
+			return varExpr(name[0]), nil
+		}
+		return nil, fmt.Errorf("unknown name '%s' at position %d", p.s[start:p.pos], start+1)
+	}
+	return nil, fmt.Errorf("unexpected '%c' at position %d", c, start+1)
+}
+
+// Parses the bracketed arguments of a function call
+func (p *exprParser) parseCall(name string, n int, fn func(a []float64) float64, start int) (expr, error) {
+	if !p.accept("(") {
+		return nil, fmt.Errorf("missing '(' after '%s' at position %d", name, start+1)
+	}
+	var args []expr
+	for {
+		a, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+		if p.accept(",") {
+			continue
+		}
+		if p.accept(")") {
+			break
+		}
+		return nil, fmt.Errorf("missing ')' for '%s' at position %d", name, start+1)
+	}
+	if len(args) != n {
+		return nil, fmt.Errorf("'%s' takes %d argument(s), not %d", name, n, len(args))
+	}
+	return callExpr{name: name, fn: fn, args: args}, nil
+}
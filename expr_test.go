@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		expr    string
+		x, y, t float64
+		want    float64
+	}{
+		{"1 + 2 * 3", 0, 0, 0, 7},
+		{"(1 + 2) * 3", 0, 0, 0, 9},
+		{"2 ^ 3 ^ 2", 0, 0, 0, 512},
+		{"2 ** 3", 0, 0, 0, 8},
+		{"-2 ^ 2", 0, 0, 0, -4},
+		{"x * y - t", 2, 3, 1, 5},
+		{"sin(pi / 2) + cos(0)", 0, 0, 0, 2},
+		{"sqrt(x^2 + y^2)", 3, 4, 0, 5},
+		{"10 / 4", 0, 0, 0, 2.5},
+		{"ln(e) + log(100)", 0, 0, 0, 3},
+	}
+	for _, tt := range tests {
+		e, err := parseExpr(tt.expr)
+		if err != nil {
+			t.Errorf("parseExpr(%q) gave an error: %v", tt.expr, err)
+			continue
+		}
+		if got := e.eval(&exprVars{x: tt.x, y: tt.y, t: tt.t}); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("parseExpr(%q) evaluated to %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	for _, s := range []string{"", "1 +", "(1 + 2", "1 2", "sin()", "foo(1)", "q + 1", "2 * * 3"} {
+		if _, err := parseExpr(s); err == nil {
+			t.Errorf("parseExpr(%q) should have given an error", s)
+		}
+	}
+	if _, err := parseExprVars("x + y", "x"); err == nil {
+		t.Error("parseExprVars should reject variables not in the list")
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// A triangle of a convex hull being built, facing outwards
+type hullFace struct {
+	a, b, c int
+	n       Point   // Unit normal
+	d       float64 // Distance of the plane from the origin along the normal
+	outside []int   // Points in front of the face, not yet inside the hull
+	dead    bool
+}
+
+// Returns how far in front of a hull face a point is
+func (f *hullFace) height(p Point) float64 {
+	return dot(f.n, p) - f.d
+}
+
+// Returns the triangles of the convex hull of a set of points, wound counter-clockwise seen from outside, using the
+// quickhull algorithm.  Points which aren't valid numbers are ignored.  An error is returned if the points don't
+// enclose any volume (eg they're all in a plane)
+func convexHull(pts []Point) ([]Surface, error) {
+	var idx []int
+	for i, p := range pts {
+		if validPoint(p) {
+			idx = append(idx, i)
+		}
+	}
+	if len(idx) < 4 {
+		return nil, errors.New("a hull needs at least four points")
+	}
+	box, _ := pointsAABB(pts)
+	eps := 1e-9 * distance(box.Min, box.Max)
+
+	// Start with a tetrahedron between extreme points.  The two furthest apart along X, then the furthest from the line
+	// between them, then the furthest from the plane through all three
+	sub := func(a Point, b Point) Point { return Point{X: a.X - b.X, Y: a.Y - b.Y, Z: a.Z - b.Z} }
+	p0, p1 := idx[0], idx[0]
+	for _, i := range idx {
+		if pts[i].X < pts[p0].X {
+			p0 = i
+		}
+		if pts[i].X > pts[p1].X {
+			p1 = i
+		}
+	}
+	if p0 == p1 {
+		p1 = -1
+		for _, i := range idx {
+			if p1 == -1 || distance(pts[i], pts[p0]) > distance(pts[p1], pts[p0]) {
+				p1 = i
+			}
+		}
+	}
+	p2, best := -1, eps
+	for _, i := range idx {
+		n := triangleNormal(pts[p0], pts[p1], pts[i])
+		if d := math.Sqrt(dot(n, n)); d > best {
+			p2, best = i, d
+		}
+	}
+	if p2 == -1 {
+		return nil, errors.New("the points are all in a line")
+	}
+	base, _ := planeFrom(pts[p0], pts[p1], pts[p2])
+	p3, best := -1, eps
+	for _, i := range idx {
+		if d := math.Abs(dot(base.n, pts[i]) - base.w); d > best {
+			p3, best = i, d
+		}
+	}
+	if p3 == -1 {
+		return nil, errors.New("the points are all in a plane")
+	}
+
+	var faces []*hullFace
+	edgeFace := make(map[[2]int]*hullFace) // The face using each directed edge
+	addFace := func(a int, b int, c int) *hullFace {
+		pl, _ := planeFrom(pts[a], pts[b], pts[c])
+		f := &hullFace{a: a, b: b, c: c, n: pl.n, d: pl.w}
+		faces = append(faces, f)
+		edgeFace[[2]int{a, b}], edgeFace[[2]int{b, c}], edgeFace[[2]int{c, a}] = f, f, f
+		return f
+	}
+	if dot(base.n, sub(pts[p3], pts[p0])) > 0 {
+		// The fourth point is in front of the base, so turn the base around to face away from it
+		p1, p2 = p2, p1
+	}
+	initial := []*hullFace{addFace(p0, p1, p2), addFace(p0, p3, p1), addFace(p1, p3, p2), addFace(p2, p3, p0)}
+
+	// Give each remaining point to the first face it's in front of.  Points behind every face are inside already
+	assign := func(points []int, candidates []*hullFace) {
+		for _, i := range points {
+			for _, f := range candidates {
+				if f.height(pts[i]) > eps {
+					f.outside = append(f.outside, i)
+					break
+				}
+			}
+		}
+	}
+	var rest []int
+	for _, i := range idx {
+		if i != p0 && i != p1 && i != p2 && i != p3 {
+			rest = append(rest, i)
+		}
+	}
+	assign(rest, initial)
+
+	// Keep adding the furthest outside point of any face, replacing the faces it can see
+	for k := 0; k < len(faces); k++ {
+		f := faces[k]
+		if f.dead || len(f.outside) == 0 {
+			continue
+		}
+		eye := f.outside[0]
+		for _, i := range f.outside {
+			if f.height(pts[i]) > f.height(pts[eye]) {
+				eye = i
+			}
+		}
+
+		// Find every face the point can see, spreading out from this one across shared edges
+		visible := []*hullFace{f}
+		seen := map[*hullFace]bool{f: true}
+		for v := 0; v < len(visible); v++ {
+			g := visible[v]
+			for _, e := range [][2]int{{g.a, g.b}, {g.b, g.c}, {g.c, g.a}} {
+				nb := edgeFace[[2]int{e[1], e[0]}]
+				if nb != nil && !seen[nb] && nb.height(pts[eye]) > eps {
+					seen[nb] = true
+					visible = append(visible, nb)
+				}
+			}
+		}
+
+		// The edges around the visible faces form the horizon.  Each gets a new face joining it to the point
+		var orphans []int
+		var horizon [][2]int
+		for _, g := range visible {
+			g.dead = true
+			orphans = append(orphans, g.outside...)
+			for _, e := range [][2]int{{g.a, g.b}, {g.b, g.c}, {g.c, g.a}} {
+				if nb := edgeFace[[2]int{e[1], e[0]}]; nb == nil || !seen[nb] {
+					horizon = append(horizon, e)
+				}
+			}
+		}
+		for _, g := range visible {
+			for _, e := range [][2]int{{g.a, g.b}, {g.b, g.c}, {g.c, g.a}} {
+				if edgeFace[e] == g {
+					delete(edgeFace, e)
+				}
+			}
+		}
+		var added []*hullFace
+		for _, e := range horizon {
+			added = append(added, addFace(e[0], e[1], eye))
+		}
+		var remaining []int
+		for _, i := range orphans {
+			if i != eye {
+				remaining = append(remaining, i)
+			}
+		}
+		assign(remaining, added)
+	}
+
+	var tris []Surface
+	for _, f := range faces {
+		if !f.dead {
+			tris = append(tris, Surface{f.a, f.b, f.c})
+		}
+	}
+	return tris, nil
+}
+
+// Returns a closed object wrapping the given points in their convex hull.  Only the points on the hull are kept, in
+// their original order
+func hullObject(pts []Point, colour string) (Object, error) {
+	tris, err := convexHull(pts)
+	if err != nil {
+		return Object{}, err
+	}
+	onHull := make([]bool, len(pts))
+	for _, t := range tris {
+		onHull[t[0]], onHull[t[1]], onHull[t[2]] = true, true, true
+	}
+	newIdx := make(map[int]int)
+	var o Object
+	for i, p := range pts {
+		if onHull[i] {
+			newIdx[i] = len(o.P)
+			o.P = append(o.P, Point{X: p.X, Y: p.Y, Z: p.Z})
+		}
+	}
+	for _, t := range tris {
+		o.S = append(o.S, Surface{newIdx[t[0]], newIdx[t[1]], newIdx[t[2]]})
+	}
+	o.C = colour
+	o.AutoEdges = true
+	o.FeatureAngle = csgFeatureAngle
+	return o, nil
+}
+
+// Adds the convex hull of the named object's points to the scene, as a new object placed alongside it.  Returns the
+// name of the new object
+func addHull(name string) (string, error) {
+	n := findNode(sceneRoot, name)
+	if n == nil || n.Mesh == nil {
+		return "", fmt.Errorf("unknown object: %v", name)
+	}
+	colour := n.Mesh.C
+	if n.Colour != "" {
+		colour = n.Colour
+	}
+	if colour == "" {
+		colour = theme.Point
+	}
+	o, err := hullObject(n.Mesh.P, colour)
+	if err != nil {
+		return "", fmt.Errorf("can't make a hull for %v: %v", n.Name, err)
+	}
+	hullName := uniqueMeshName(n.Name + " hull")
+	h := addNode(n.Parent, hullName, newMesh(hullName, o), 0, 0, 0)
+	h.Local = n.Local
+	updateWorldSpace()
+	return hullName, nil
+}
+
+// Adds the convex hull of the selected object's points to the scene
+func addSelectedHull() {
+	if selected == "" {
+		opText = "Click on an object to select it first."
+		return
+	}
+	name, err := addHull(selected)
+	if err != nil {
+		opText = err.Error()
+		return
+	}
+	opText = fmt.Sprintf("Added %v, with %d of %d points on the hull.", name, len(worldSpace[name].P), len(worldSpace[selected].P))
+}
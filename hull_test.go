@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestConvexHull(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var sphere, cloud []Point
+	for i := 0; i < 500; i++ {
+		// Points spread evenly over a unit sphere, and points scattered through a cube
+		z := 2*r.Float64() - 1
+		a := 2 * math.Pi * r.Float64()
+		s := math.Sqrt(1 - z*z)
+		sphere = append(sphere, Point{X: s * math.Cos(a), Y: s * math.Sin(a), Z: z})
+		cloud = append(cloud, Point{X: r.Float64(), Y: r.Float64(), Z: r.Float64()})
+	}
+	corners := cube(2, "red").P
+	tests := []struct {
+		name     string
+		pts      []Point
+		min, max float64 // Range the volume should be in
+	}{
+		{"sphere", sphere, 3.9, 4 * math.Pi / 3},
+		{"cloud", cloud, 0.85, 1},
+		{"cube", corners, 8 - 1e-9, 8 + 1e-9},
+	}
+	for _, tt := range tests {
+		o, err := hullObject(tt.pts, "red")
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		if v := signedVolume(o); v < tt.min || v > tt.max {
+			t.Errorf("%v: volume is %v, want between %v and %v", tt.name, v, tt.min, tt.max)
+		}
+		if top := analyseTopology(o); !top.closed() || top.Euler != 2 {
+			t.Errorf("%v: hull isn't a closed surface: %v", tt.name, top)
+		}
+
+		// Every point should be inside (or on) every face
+		tris, _ := convexHull(tt.pts)
+		for _, f := range tris {
+			a, b, c := tt.pts[f[0]], tt.pts[f[1]], tt.pts[f[2]]
+			n := triangleNormal(a, b, c)
+			for _, p := range tt.pts {
+				if dot(n, Point{X: p.X - a.X, Y: p.Y - a.Y, Z: p.Z - a.Z}) > 1e-9 {
+					t.Fatalf("%v: point %v is outside the hull", tt.name, p)
+				}
+			}
+		}
+	}
+}
+
+func TestConvexHullFlat(t *testing.T) {
+	flat := []Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 1}, {X: 0.5, Y: 0.5}}
+	line := []Point{{X: 0}, {X: 1}, {X: 2}, {X: 3}}
+	for _, pts := range [][]Point{flat, line, flat[:3]} {
+		if _, err := convexHull(pts); err == nil {
+			t.Errorf("the hull of %v should give an error", pts)
+		}
+	}
+}
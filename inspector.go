@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Height of each row in the object inspector
+const inspectorRowHeight = 20
+
+// An editable value shown in the inspector, along with where it was last drawn
+type inspectorField struct {
+	x, y, w, h float64
+	object     string
+	point      int // Index of the point in the object.  -1 for the object colour
+	axis       int // 0 for X, 1 for Y, 2 for Z
+}
+
+// The clickable title row of an object's section in the inspector
+type inspectorHeader struct {
+	y, h   float64
+	object string
+}
+
+var (
+	inspectorCollapsed = make(map[string]bool) // Objects whose inspector section is collapsed
+	inspectorScroll    float64                 // How far the inspector is scrolled down, in pixels
+	inspectorMaxScroll float64
+	inspectorFields    []inspectorField  // Editable values drawn in the last frame
+	inspectorHeaders   []inspectorHeader // Section headers drawn in the last frame
+	inspectorEdit      *inspectorField   // The value being edited.  nil when nothing is
+	inspectorText      string            // The text typed so far for the value being edited
+)
+
+// Returns the names of the objects in the given world space, in alphabetical order
+func sortedNames(ws map[string]Object) (names []string) {
+	for name := range ws {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Draws the object inspector into the side panel, between the given top and bottom Y co-ordinates
+func drawInspector(top float64, bottom float64) {
+	inspectorFields = inspectorFields[:0]
+	inspectorHeaders = inspectorHeaders[:0]
+
+	// Set the clip region so the rows scroll underneath the rest of the panel
+	ctx.Call("save")
+	ctx.Call("beginPath")
+	ctx.Call("rect", graphWidth, top, width-graphWidth, bottom-top)
+	ctx.Call("clip")
+
+	y := top + inspectorRowHeight - inspectorScroll
+	for _, name := range sortedNames(worldSpace) {
+		o := worldSpace[name]
+
+		// Draw the section header, with the object colour
+		arrow := "▾"
+		if inspectorCollapsed[name] {
+			arrow = "▸"
+		}
+		if inspectorRowShown(y, top, bottom) {
+			ctx.Set("fillStyle", theme.Heading)
+			ctx.Set("font", theme.HeadingFont)
+			ctx.Call("fillText", arrow+" "+name, graphWidth+20, y)
+			ctx.Set("fillStyle", o.C)
+			ctx.Call("fillRect", graphWidth+130, y-11, 12, 12)
+			drawInspectorField(inspectorField{x: graphWidth + 150, y: y, w: 90, object: name, point: -1}, o.C, top, bottom)
+		}
+		if y > top && y < bottom {
+			inspectorHeaders = append(inspectorHeaders, inspectorHeader{y: y - inspectorRowHeight + 5, h: inspectorRowHeight, object: name})
+		}
+		y += inspectorRowHeight
+		if inspectorCollapsed[name] {
+			continue
+		}
+
+		// Draw the point co-ordinates.  Objects can have thousands of points, so only the rows which show in the panel
+		// are drawn, with the others just taking up their space
+		for i, l := range o.P {
+			if y-inspectorRowHeight > bottom {
+				y += float64(len(o.P)-i) * inspectorRowHeight
+				break
+			}
+			if !inspectorRowShown(y, top, bottom) {
+				y += inspectorRowHeight
+				continue
+			}
+			ctx.Set("fillStyle", theme.Heading)
+			ctx.Set("font", theme.HeadingFont)
+			ctx.Call("fillText", pointLabel(o, i)+":", graphWidth+30, y)
+			for axis, v := range [3]float64{l.X, l.Y, l.Z} {
+				f := inspectorField{x: graphWidth + 100 + float64(axis*55), y: y, w: 50, object: name, point: i, axis: axis}
+				drawInspectorField(f, fmt.Sprintf("%0.1f", v), top, bottom)
+			}
+			y += inspectorRowHeight
+		}
+	}
+
+	// Keep the scroll position within the content
+	inspectorMaxScroll = math.Max(0, y+inspectorScroll-top-(bottom-top))
+	inspectorScroll = math.Min(inspectorScroll, inspectorMaxScroll)
+
+	// Draw a scroll bar when not everything fits
+	if inspectorMaxScroll > 0 {
+		visible := bottom - top
+		barH := visible * visible / (visible + inspectorMaxScroll)
+		barY := top + (visible-barH)*(inspectorScroll/inspectorMaxScroll)
+		ctx.Set("fillStyle", theme.ScrollBar)
+		ctx.Call("fillRect", width-8, barY, 4, barH)
+	}
+	ctx.Call("restore")
+}
+
+// Returns whether the inspector row drawn at the given Y co-ordinate shows between top and bottom
+func inspectorRowShown(y float64, top float64, bottom float64) bool {
+	return y+5 > top && y-inspectorRowHeight+5 < bottom
+}
+
+// Draws a single editable value in the inspector, remembering where it is so clicks on it can be detected
+func drawInspectorField(f inspectorField, text string, top float64, bottom float64) {
+	f.h = inspectorRowHeight
+	f.y -= inspectorRowHeight - 5
+	ctx.Set("font", theme.ValueFont)
+	if inspectorEdit != nil && inspectorEdit.object == f.object && inspectorEdit.point == f.point && inspectorEdit.axis == f.axis {
+		// This is the value being edited, so show what's been typed so far
+		ctx.Set("strokeStyle", theme.FieldBorder)
+		ctx.Set("lineWidth", "1")
+		ctx.Call("strokeRect", f.x-2, f.y+2, f.w, f.h-4)
+		text = inspectorText + "|"
+	}
+	ctx.Set("fillStyle", theme.Value)
+	ctx.Call("fillText", text, f.x, f.y+inspectorRowHeight-5)
+	if f.y+f.h > top && f.y < bottom {
+		inspectorFields = append(inspectorFields, f)
+	}
+}
+
+// Handles mouse clicks in the side panel, toggling sections open or closed, and starting the editing of values
+func inspectorClick(x float64, y float64) {
+	// Clicking anywhere finishes any edit already in progress
+	if inspectorEdit != nil {
+		inspectorCommit()
+	}
+	for _, f := range inspectorFields {
+		if x >= f.x && x < f.x+f.w && y >= f.y && y < f.y+f.h {
+			o, ok := worldSpace[f.object]
+			if !ok {
+				return
+			}
+			field := f
+			inspectorEdit = &field
+			if f.point == -1 {
+				inspectorText = o.C
+				return
+			}
+			v := [3]float64{o.P[f.point].X, o.P[f.point].Y, o.P[f.point].Z}[f.axis]
+			inspectorText = strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+			return
+		}
+	}
+	for _, h := range inspectorHeaders {
+		if y >= h.y && y < h.y+h.h {
+			inspectorCollapsed[h.object] = !inspectorCollapsed[h.object]
+			return
+		}
+	}
+}
+
+// Handles key presses while a value is being edited
+func inspectorKey(key string) {
+	switch key {
+	case "Enter", "Tab":
+		inspectorCommit()
+	case "Escape":
+		inspectorEdit = nil
+	case "Backspace":
+		_, size := utf8.DecodeLastRuneInString(inspectorText)
+		inspectorText = inspectorText[:len(inspectorText)-size]
+	default:
+		// Other named keys (eg "Shift") are longer than one character, and are ignored
+		if len([]rune(key)) == 1 {
+			inspectorText += key
+		}
+	}
+}
+
+// Applies the value being edited to its object
+func inspectorCommit() {
+	if renderActive.Load() {
+		// The operation in progress is changing the objects, so leave the value being edited until it's finished
+		opText = "Wait for the operation in progress to finish before changing values."
+		return
+	}
+	f := inspectorEdit
+	inspectorEdit = nil
+	n := findNode(sceneRoot, f.object)
+	o, ok := worldSpace[f.object]
+	if n == nil || n.Mesh == nil || !ok {
+		return
+	}
+	txt := strings.TrimSpace(inspectorText)
+	if f.point == -1 {
+		// Colour changes only apply to this node, not to every user of the mesh
+		if txt != "" {
+			n.Colour = txt
+			updateWorldSpace()
+		}
+		return
+	}
+	v, err := strconv.ParseFloat(txt, 64)
+	if err != nil || f.point >= len(o.P) {
+		opText = fmt.Sprintf("Invalid number: %v", txt)
+		return
+	}
+
+	// The inspector shows world space co-ordinates, so the new position is converted back into the node's own space
+	p := o.P[f.point]
+	switch f.axis {
+	case 0:
+		p.X = v
+	case 1:
+		p.Y = v
+	case 2:
+		p.Z = v
+	}
+	setWorldPoint(n, f.point, p)
+	updateWorldSpace()
+}
+
+// Scrolls the inspector by the given mouse wheel delta
+func inspectorScrollBy(delta float64) {
+	if math.Abs(delta) < 10 {
+		// Some browsers report the wheel movement in lines rather than pixels
+		delta *= inspectorRowHeight
+	}
+	inspectorScroll = math.Max(0, math.Min(inspectorScroll+delta, inspectorMaxScroll))
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	useLOD               = true                   // If true, objects drawn small on screen use a simplified mesh
+	lodMinTriangles      = 200                    // Meshes with fewer triangles than this are always drawn in full
+	lodPixelsPerTriangle = 30.0                   // Roughly how many square pixels of screen each drawn triangle should cover
+	lodBoundaryWeight    = 100.0                  // How strongly decimation keeps open edges in place, compared to the surfaces
+	lodSettleTime        = 500 * time.Millisecond // How long a mesh needs to stay unchanged before it's simplified again
+)
+
+// A quadric error measure, the sum of the squared distances from a point to a set of planes.  Stored as the upper
+// triangle of the symmetric 4x4 matrix: xx, xy, xz, xw, yy, yz, yw, zz, zw, ww
+type quadric [10]float64
+
+// Returns the quadric for a plane ax + by + cz + d = 0 (with a unit normal), scaled by the given weight
+func planeQuadric(a float64, b float64, c float64, d float64, w float64) quadric {
+	return quadric{w * a * a, w * a * b, w * a * c, w * a * d, w * b * b, w * b * c, w * b * d, w * c * c, w * c * d, w * d * d}
+}
+
+// Returns the sum of two quadrics
+func (q quadric) add(r quadric) (s quadric) {
+	for i := range q {
+		s[i] = q[i] + r[i]
+	}
+	return
+}
+
+// Returns the error of moving a point to the given position
+func (q quadric) error(p Point) float64 {
+	x, y, z := p.X, p.Y, p.Z
+	return q[0]*x*x + 2*q[1]*x*y + 2*q[2]*x*z + 2*q[3]*x + q[4]*y*y + 2*q[5]*y*z + 2*q[6]*y + q[7]*z*z + 2*q[8]*z + q[9]
+}
+
+// Returns the position with the least error.  The bool is false if there isn't a single best position (eg the planes
+// are all parallel)
+func (q quadric) optimal() (Point, bool) {
+	a, b, c := q[0], q[1], q[2]
+	d, e, f := q[1], q[4], q[5]
+	g, h, i := q[2], q[5], q[7]
+	det := a*(e*i-f*h) - b*(d*i-f*g) + c*(d*h-e*g)
+	scale := math.Abs(a) + math.Abs(e) + math.Abs(i)
+	if scale == 0 || math.Abs(det) < 1e-9*scale*scale*scale {
+		return Point{}, false
+	}
+	rx, ry, rz := -q[3], -q[6], -q[8]
+	return Point{
+		X: (rx*(e*i-f*h) - b*(ry*i-f*rz) + c*(ry*h-e*rz)) / det,
+		Y: (a*(ry*i-f*rz) - rx*(d*i-f*g) + c*(d*rz-ry*g)) / det,
+		Z: (a*(e*rz-ry*h) - b*(d*rz-ry*g) + rx*(d*h-e*g)) / det,
+	}, true
+}
+
+// A candidate edge collapse, moving points a and b together to pos
+type collapse struct {
+	cost       float64
+	a, b       int
+	pos        Point
+	verA, verB int // Versions of the points when the cost was worked out, to spot out of date candidates
+}
+
+// A priority queue of edge collapses, cheapest first
+type collapseHeap []collapse
+
+func (h collapseHeap) Len() int            { return len(h) }
+func (h collapseHeap) Less(i, j int) bool  { return h[i].cost < h[j].cost }
+func (h collapseHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *collapseHeap) Push(x interface{}) { *h = append(*h, x.(collapse)) }
+func (h *collapseHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// Returns the normal of a triangle, which is twice its area long
+func triangleNormal(a Point, b Point, c Point) Point {
+	ux, uy, uz := b.X-a.X, b.Y-a.Y, b.Z-a.Z
+	vx, vy, vz := c.X-a.X, c.Y-a.Y, c.Z-a.Z
+	return Point{X: uy*vz - uz*vy, Y: uz*vx - ux*vz, Z: ux*vy - uy*vx}
+}
+
+// Simplifies an object's surfaces down to about the target number of triangles, by repeatedly collapsing the edge
+// which changes the shape least (Garland and Heckbert's quadric error metric).  Open edges are weighted heavily so the
+// outline keeps its shape, and collapses which would flip a triangle over or pinch the surface are skipped.  The result
+// has triangles for surfaces, and only the points still in use (plus any not part of a surface to begin with), with
+// their labels, colours, sizes, and scalars carried along
+func decimate(o Object, target int) Object {
+	var tris [][3]int
+	for _, t := range surfaceTriangles(o) {
+		for _, tri := range t {
+			if validPoint(o.P[tri.A]) && validPoint(o.P[tri.B]) && validPoint(o.P[tri.C]) {
+				tris = append(tris, [3]int{tri.A, tri.B, tri.C})
+			}
+		}
+	}
+	pts := append([]Point(nil), o.P...)
+	inSurface := make([]bool, len(pts))
+	pointTris := make([][]int, len(pts))
+	quadrics := make([]quadric, len(pts))
+	edgeTris := make(map[[2]int][]int)
+	for t, tri := range tris {
+		n := triangleNormal(pts[tri[0]], pts[tri[1]], pts[tri[2]])
+		l := math.Sqrt(n.X*n.X + n.Y*n.Y + n.Z*n.Z)
+		var q quadric
+		if l > 0 {
+			p := pts[tri[0]]
+			a, b, c := n.X/l, n.Y/l, n.Z/l
+			q = planeQuadric(a, b, c, -(a*p.X + b*p.Y + c*p.Z), l/2)
+		}
+		for j, v := range tri {
+			inSurface[v] = true
+			pointTris[v] = append(pointTris[v], t)
+			quadrics[v] = quadrics[v].add(q)
+			k := edgeKey(v, tri[(j+1)%3])
+			edgeTris[k] = append(edgeTris[k], t)
+		}
+	}
+
+	// Open edges get a plane at right angles to their triangle, so collapses don't pull the outline in
+	for k, ts := range edgeTris {
+		if len(ts) != 1 {
+			continue
+		}
+		tri := tris[ts[0]]
+		p, q := pts[k[0]], pts[k[1]]
+		n := triangleNormal(pts[tri[0]], pts[tri[1]], pts[tri[2]])
+		ex, ey, ez := q.X-p.X, q.Y-p.Y, q.Z-p.Z
+		m := Point{X: ey*n.Z - ez*n.Y, Y: ez*n.X - ex*n.Z, Z: ex*n.Y - ey*n.X}
+		l := math.Sqrt(m.X*m.X + m.Y*m.Y + m.Z*m.Z)
+		if l == 0 {
+			continue
+		}
+		a, b, c := m.X/l, m.Y/l, m.Z/l
+		bq := planeQuadric(a, b, c, -(a*p.X + b*p.Y + c*p.Z), lodBoundaryWeight*(ex*ex+ey*ey+ez*ez))
+		quadrics[k[0]] = quadrics[k[0]].add(bq)
+		quadrics[k[1]] = quadrics[k[1]].add(bq)
+	}
+
+	dead := make([]bool, len(tris))
+	removed := make([]bool, len(pts))
+	version := make([]int, len(pts))
+	live := len(tris)
+
+	// Returns the points sharing a live triangle with v
+	neighbours := func(v int) map[int]bool {
+		nb := make(map[int]bool)
+		for _, t := range pointTris[v] {
+			if dead[t] {
+				continue
+			}
+			for _, w := range tris[t] {
+				if w != v {
+					nb[w] = true
+				}
+			}
+		}
+		return nb
+	}
+
+	// Works out the cost of collapsing an edge, and where the joined point should go
+	h := &collapseHeap{}
+	candidate := func(a int, b int) {
+		q := quadrics[a].add(quadrics[b])
+		pos, ok := q.optimal()
+		if !ok {
+			// Fall back to the best of the ends and the middle
+			pos = pts[a]
+			mid := Point{X: (pts[a].X + pts[b].X) / 2, Y: (pts[a].Y + pts[b].Y) / 2, Z: (pts[a].Z + pts[b].Z) / 2}
+			for _, p := range []Point{pts[b], mid} {
+				if q.error(p) < q.error(pos) {
+					pos = p
+				}
+			}
+		}
+		heap.Push(h, collapse{cost: q.error(pos), a: a, b: b, pos: pos, verA: version[a], verB: version[b]})
+	}
+	for k := range edgeTris {
+		candidate(k[0], k[1])
+	}
+
+	// Returns true if collapsing the edge would leave the surface in a bad state
+	blocked := func(c collapse) bool {
+		// Only points shared with the triangles along the edge may be neighbours of both ends, otherwise the surface
+		// gets pinched together
+		nbA, nbB := neighbours(c.a), neighbours(c.b)
+		shared := 0
+		for _, t := range pointTris[c.a] {
+			if !dead[t] && (tris[t][0] == c.b || tris[t][1] == c.b || tris[t][2] == c.b) {
+				shared++
+			}
+		}
+		common := 0
+		for v := range nbA {
+			if nbB[v] {
+				common++
+			}
+		}
+		if common != shared {
+			return true
+		}
+
+		// No triangle which stays may flip over or collapse to nothing
+		for _, v := range []int{c.a, c.b} {
+			for _, t := range pointTris[v] {
+				tri := tris[t]
+				if dead[t] || ((tri[0] == c.a || tri[1] == c.a || tri[2] == c.a) && (tri[0] == c.b || tri[1] == c.b || tri[2] == c.b)) {
+					continue
+				}
+				var moved [3]Point
+				for j, w := range tri {
+					moved[j] = pts[w]
+					if w == v {
+						moved[j] = c.pos
+					}
+				}
+				before := triangleNormal(pts[tri[0]], pts[tri[1]], pts[tri[2]])
+				after := triangleNormal(moved[0], moved[1], moved[2])
+				if before.X*after.X+before.Y*after.Y+before.Z*after.Z <= 0 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for live > target && h.Len() > 0 {
+		c := heap.Pop(h).(collapse)
+		if removed[c.a] || removed[c.b] || version[c.a] != c.verA || version[c.b] != c.verB || blocked(c) {
+			continue
+		}
+
+		// Move a to the new position, and hand b's triangles over to it
+		pts[c.a].X, pts[c.a].Y, pts[c.a].Z = c.pos.X, c.pos.Y, c.pos.Z
+		quadrics[c.a] = quadrics[c.a].add(quadrics[c.b])
+		for _, t := range pointTris[c.b] {
+			if dead[t] {
+				continue
+			}
+			tri := &tris[t]
+			if tri[0] == c.a || tri[1] == c.a || tri[2] == c.a {
+				dead[t] = true
+				live--
+				continue
+			}
+			for j := range tri {
+				if tri[j] == c.b {
+					tri[j] = c.a
+				}
+			}
+			pointTris[c.a] = append(pointTris[c.a], t)
+		}
+		removed[c.b] = true
+		version[c.a]++
+		for v := range neighbours(c.a) {
+			version[v]++
+		}
+		for v := range neighbours(c.a) {
+			candidate(c.a, v)
+			for w := range neighbours(v) {
+				if w != c.a {
+					candidate(minInt(v, w), maxInt(v, w))
+				}
+			}
+		}
+	}
+
+	// Gather up what's left, numbering the kept points in their original order
+	used := make([]bool, len(pts))
+	for t, tri := range tris {
+		if !dead[t] {
+			used[tri[0]], used[tri[1]], used[tri[2]] = true, true, true
+		}
+	}
+	var r Object
+	newIdx := make([]int, len(pts))
+	var kept []int
+	for i := range pts {
+		newIdx[i] = -1
+		if used[i] || !inSurface[i] {
+			newIdx[i] = len(r.P)
+			r.P = append(r.P, pts[i])
+			kept = append(kept, i)
+		}
+	}
+	for t, tri := range tris {
+		if !dead[t] {
+			r.S = append(r.S, Surface{newIdx[tri[0]], newIdx[tri[1]], newIdx[tri[2]]})
+		}
+	}
+	keep := func(vals []float64) []float64 {
+		if len(vals) != len(o.P) {
+			return nil
+		}
+		k := make([]float64, len(kept))
+		for i, j := range kept {
+			k[i] = vals[j]
+		}
+		return k
+	}
+	if len(o.Labels) == len(o.P) {
+		for _, j := range kept {
+			r.Labels = append(r.Labels, o.Labels[j])
+		}
+	}
+	if len(o.PColour) == len(o.P) {
+		for _, j := range kept {
+			r.PColour = append(r.PColour, o.PColour[j])
+		}
+	}
+	r.PSize = keep(o.PSize)
+	if o.Scalars != nil {
+		r.Scalars = make(map[string][]float64, len(o.Scalars))
+		for name, vals := range o.Scalars {
+			r.Scalars[name] = keep(vals)
+		}
+	}
+	r.C, r.ColourBy, r.ColourMap = o.C, o.ColourBy, o.ColourMap
+	r.HidePoints, r.HideLabels = o.HidePoints, o.HideLabels
+	r.FeatureAngle = o.FeatureAngle
+	r.AutoEdges = true
+	r.Mid = midPoint(r.P)
+	r.T = triangulateObject(r)
+	r.E = deriveEdges(r)
+	return r
+}
+
+// Returns the simplified copies of a mesh, most detailed first.  Each has about half the triangles of the one before,
+// stopping once they're too few to bother with or can't be reduced much further.  Decimating a large mesh takes a
+// while, so when they're first needed they're built one per call (ie one per frame), with nil returned (meaning the
+// mesh is drawn in full) until they're all ready.  While the mesh is being animated or dragged they'd be out of date by
+// the next frame, so none are built until it settles down
+func meshLODs(m *Mesh) []Object {
+	if m.lodsDone {
+		return m.LODs
+	}
+	if renderActive.Load() || dragging != nil || time.Since(m.changed) < lodSettleTime {
+		return nil
+	}
+	prev := m.Object
+	if len(m.LODs) > 0 {
+		prev = m.LODs[len(m.LODs)-1]
+	}
+	if len(prev.T) >= lodMinTriangles/2 {
+		next := decimate(prev, len(prev.T)/2)
+		if len(next.T) <= len(prev.T)*9/10 {
+			m.LODs = append(m.LODs, next)
+			return nil
+		}
+	}
+	m.lodsDone = true
+	return m.LODs
+}
+
+// Returns the version of an object to draw, picking a simplified one if it's too small on screen for all of its
+// triangles to be seen.  n is the scene node the world space object comes from
+func lodObject(n *Node, o Object, step float64) Object {
+	if !useLOD || len(o.T) < lodMinTriangles {
+		return o
+	}
+	s, ok := pointsSphere(o.P)
+	if !ok {
+		return o
+	}
+	size := 2 * s.Radius * step
+	budget := int(size * size / lodPixelsPerTriangle)
+	if len(o.T) <= budget {
+		return o
+	}
+	if n == nil || n.Mesh == nil {
+		return o
+	}
+	lods := meshLODs(n.Mesh)
+	if len(lods) == 0 {
+		return o
+	}
+	level := len(lods)
+	for i, l := range lods {
+		if len(l.T) <= budget {
+			level = i + 1
+			break
+		}
+	}
+
+	// Move the simplified copy into world space, keeping the world space object's display settings as these can change
+	// without the mesh changing
+	l := lods[level-1]
+	w := worldMatrix(n)
+	pts := make([]Point, len(l.P))
+	for i, p := range l.P {
+		pts[i] = transform(w, p)
+	}
+	l.P = pts
+	l.Mid = transform(w, l.Mid)
+	l.C, l.ColourBy, l.ColourMap = o.C, o.ColourBy, o.ColourMap
+	l.HidePoints, l.HideLabels = o.HidePoints, o.HideLabels
+	return l
+}
+
+// Turns drawing simplified meshes for small objects on or off
+func toggleLOD() {
+	useLOD = !useLOD
+	if useLOD {
+		opText = fmt.Sprintf("Simplifying meshes of more than %d triangles when drawn small.", lodMinTriangles)
+		return
+	}
+	opText = "Drawing all meshes in full."
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// Returns the volume enclosed by an object's triangles, which is negative if they face inwards
+func trianglesVolume(o Object) (v float64) {
+	for _, t := range o.T {
+		a, b, c := o.P[t.A], o.P[t.B], o.P[t.C]
+		v += (a.X*(b.Y*c.Z-b.Z*c.Y) + a.Y*(b.Z*c.X-b.X*c.Z) + a.Z*(b.X*c.Y-b.Y*c.X)) / 6
+	}
+	return
+}
+
+func TestDecimate(t *testing.T) {
+	tests := []struct {
+		name string
+		o    Object
+	}{
+		{"sphere", uvSphere(1.5, 32, 16, "red")},
+		{"torus", torus(1.5, 0.5, 32, 16, "red")},
+		{"icosphere", icoSphere(1.5, 3, "red")},
+	}
+	for _, tt := range tests {
+		o := importObject(tt.o)
+		before := analyseTopology(o)
+		target := len(o.T) / 2
+		r := decimate(o, target)
+		if len(r.T) > target+target/10 {
+			t.Errorf("%v: decimated to %d triangles, wanted about %d", tt.name, len(r.T), target)
+		}
+		after := analyseTopology(r)
+		if after.closed() != before.closed() || after.Euler != before.Euler {
+			t.Errorf("%v: decimation changed the topology from %v to %v", tt.name, before, after)
+		}
+		if trianglesVolume(r) <= 0 {
+			t.Errorf("%v: decimated surfaces face the wrong way", tt.name)
+		}
+	}
+}
+
+func TestMeshLODs(t *testing.T) {
+	renderActive = atomic.NewBool(false)
+	m := &Mesh{Name: "sphere", Object: importObject(uvSphere(1.5, 32, 16, "red"))}
+
+	// Nothing is built while an operation is in progress, or straight after the mesh changes
+	renderActive.Store(true)
+	if lods := meshLODs(m); lods != nil || len(m.LODs) != 0 {
+		t.Error("simplified copies were made during an operation")
+	}
+	renderActive.Store(false)
+	m.changed = time.Now()
+	if lods := meshLODs(m); lods != nil || len(m.LODs) != 0 {
+		t.Error("simplified copies were made while the mesh was still changing")
+	}
+
+	// Each call builds one more copy, with the mesh drawn in full until they're all done
+	m.changed = time.Time{}
+	var lods []Object
+	calls := 0
+	for ; lods == nil && calls < 20; calls++ {
+		lods = meshLODs(m)
+	}
+	if len(lods) == 0 {
+		t.Fatal("no simplified copies were made")
+	}
+	if calls != len(lods)+1 {
+		t.Errorf("building %d copies took %d calls", len(lods), calls)
+	}
+	prev := len(m.T)
+	for i, l := range lods {
+		if len(l.T) >= prev {
+			t.Errorf("level %d has %d triangles, which isn't fewer than the %d before", i+1, len(l.T), prev)
+		}
+		prev = len(l.T)
+	}
+}
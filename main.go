@@ -1,5 +1,5 @@
 //Wasming
-// compile: GOOS=js GOARCH=wasm go build -o main.wasm ./main.go
+// compile: GOOS=js GOARCH=wasm go build -o main.wasm .
 package main
 
 // TODO: Some of the items mentioned on the MDN "Optimizing Canvas" page look like they'll be useful:
@@ -9,6 +9,7 @@ package main
 //       * Moving the right side point display info into its own canvas layer might be useful too
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"sort"
@@ -33,9 +34,31 @@ type Surface []int
 type Object struct {
 	C   string // Colour of the object
 	P   []Point
-	E   []Edge    // List of points to connect by edges
-	S   []Surface // List of points to connect in order, to create a surface
-	Mid Point     // The mid point of the object.  Used for calculating object draw order in a very simple way
+	E   []Edge     // List of points to connect by edges
+	S   []Surface  // List of points to connect in order, to create a surface
+	T   []Triangle // The surfaces split into triangles, for filling.  Worked out from S when the object is imported
+	Mid Point      // The mid point of the object.  Used for calculating object draw order in a very simple way
+
+	Holes map[int][]Surface // Holes cut into surfaces, by the index of the surface in S
+
+	// When AutoEdges is set, the edges are worked out from the surfaces rather than being given in E.  This is turned on
+	// when importing objects which only have surfaces.  A FeatureAngle above zero leaves out the edges between surfaces
+	// meeting at less than that many degrees, so smooth areas aren't cluttered with edges
+	AutoEdges    bool
+	FeatureAngle float64
+
+	HidePoints bool // If true, the points aren't drawn or labelled on the graph.  Useful for objects with lots of points
+	HideLabels bool // If true, the points are drawn on the graph without their labels
+
+	// Optional per point details.  When given, these have one entry for each point
+	Labels  []string  // Labels for the points, used instead of their numbers
+	PColour []string  // Colours for the point dots
+	PSize   []float64 // Radius of the point dots, in pixels
+
+	// Optional scalar values for the points, by attribute name.  Each has one value per point
+	Scalars   map[string][]float64
+	ColourBy  string // Name of the scalar attribute colouring the points and surfaces.  Empty to use the plain colours
+	ColourMap string // The colour map for ColourBy.  A built in map name, or custom stops (see parseColourMap)
 }
 
 type OperationType int
@@ -44,15 +67,26 @@ const (
 	ROTATE OperationType = iota
 	SCALE
 	TRANSLATE
+	REVEAL       // Draws the target curve a part at a time, so it appears to draw itself
+	FIT          // Zooms the view by Z, after moving the X and Y view co-ordinates to the centre of the graph
+	LOOP         // Replaces the target's mesh with a Loop subdivided copy
+	CATMULLCLARK // Replaces the target's mesh with a Catmull-Clark subdivided copy
+	SMOOTH       // Replaces the target's mesh with a smoothed copy.  X is the number of passes, Y how far each moves points
+	UNION        // Adds a new object combining the target and other objects.  When X is 1, they're replaced by it
+	INTERSECT    // Adds a new object from where the target and other objects overlap.  When X is 1, they're replaced by it
+	DIFFERENCE   // Adds a new object from the target with the other object cut away.  When X is 1, they're replaced by it
 )
 
 type Operation struct {
-	op OperationType
-	t  int32 // Number of milliseconds the operation should take
-	f  int32 // Number of display frames the operation should be broken into
-	X  float64
-	Y  float64
-	Z  float64
+	op     OperationType
+	target string // Name of the scene node the operation applies to, along with its children.  Empty for the whole scene
+	other  string // Name of the second scene node, for operations combining two objects
+	t      int32  // Number of milliseconds the operation should take
+	f      int32  // Number of display frames the operation should be broken into
+	X      float64
+	Y      float64
+	Z      float64
+	done   func(result string, err error) // Optional.  Called when the operation finishes, with the name of any new object
 }
 
 type paintOrder struct {
@@ -79,7 +113,8 @@ func (p paintOrderSlice) Less(i, j int) bool {
 }
 
 var (
-	// The empty world space
+	// The scene graph, and the world space version of its objects which is worked out from it
+	sceneRoot    *Node
 	worldSpace   map[string]Object
 	pointCounter = 1
 
@@ -92,15 +127,7 @@ var (
 			{X: -1.5, Y: -1.75, Z: 1.0},
 			{X: 0, Y: 0, Z: 1.75},
 		},
-		E: []Edge{
-			{0, 1}, // Connect point 0 to point 1
-			{0, 2}, // Connect point 0 to point 2
-			{1, 2}, // Connect point 1 to point 2
-			{0, 3}, // etc
-			{1, 3},
-			{2, 3},
-		},
-		S: []Surface{
+		S: []Surface{ // List of points to connect in order.  The edges are worked out from these
 			{0, 1, 3},
 			{0, 2, 3},
 			{0, 1, 2},
@@ -114,11 +141,6 @@ var (
 			{X: 1.5, Y: -1.5, Z: -1.0}, // Point 1 for this object
 			{X: -1.5, Y: -1.5, Z: -1.0},
 		},
-		E: []Edge{
-			{0, 1}, // Connect point 0 to point 1
-			{1, 2}, // Connect point 1 to point 2
-			{2, 0}, // etc
-		},
 		S: []Surface{
 			{0, 1, 2},
 		},
@@ -132,16 +154,6 @@ var (
 			{X: -2, Y: -2, Z: 1.0},
 			{X: 0, Y: -3, Z: 2.5},
 		},
-		E: []Edge{
-			{0, 1},
-			{1, 2},
-			{2, 3},
-			{3, 0},
-			{0, 4},
-			{1, 4},
-			{2, 4},
-			{3, 4},
-		},
 		S: []Surface{
 			{0, 1, 4},
 			{1, 2, 4},
@@ -159,9 +171,22 @@ var (
 		0, 0, 0, 1,
 	}
 
+	// The demo choreography played at startup
+	demoOps = []Operation{
+		{op: ROTATE, t: 1000, f: 60, X: 0, Y: 0, Z: 90},
+		{op: SCALE, t: 1000, f: 60, X: 2.0, Y: 2.0, Z: 2.0},
+		{op: ROTATE, t: 1000, f: 60, X: 0, Y: 360, Z: 0},
+		{op: SCALE, t: 1000, f: 60, X: 0.5, Y: 0.5, Z: 0.5},
+		{op: ROTATE, t: 1000, f: 60, X: 45, Y: 0, Z: -240},
+		{op: SCALE, t: 1000, f: 60, X: 1.5, Y: 1.5, Z: 1.52},
+	}
+
 	// Initialise the transform matrix with the identity matrix
 	transformMatrix = identityMatrix
 
+	// The transform of the scene root.  This is the combination of all transformations applied to the whole scene
+	sceneMatrix = identityMatrix
+
 	// FIFO queue
 	queue        chan Operation
 	renderActive *atomic.Bool
@@ -170,16 +195,82 @@ var (
 	graphWidth          float64
 	graphHeight         float64
 	cCall, kCall, mCall js.Callback
-	rCall, wCall        js.Callback
+	rCall, uCall, wCall js.Callback
 	ctx, doc, canvasEl  js.Value
 	opText              string
+	inWorker            bool // True when running inside a Web Worker, drawing to an OffscreenCanvas
 	highLightSource     bool
 	debug               = false // If true, some debugging info is printed to the javascript console
 )
 
 func main() {
-	// Initialise canvas
+	// Work out whether we're running on the page itself, or inside a Web Worker which the page has given an
+	// OffscreenCanvas to
+	renderActive = atomic.NewBool(false)
 	doc = js.Global().Get("document")
+	if doc == js.Undefined() {
+		inWorker = true
+		initWorker()
+		defer msgCall.Release()
+	} else {
+		initPage()
+		defer cCall.Release()
+		defer kCall.Release()
+		defer mCall.Release()
+		defer uCall.Release()
+		defer wCall.Release()
+	}
+
+	// Pick the colour theme
+	initTheme()
+	initColourMap()
+
+	// Set the frame renderer going
+	rCall = js.NewCallback(renderFrame)
+	js.Global().Call("requestAnimationFrame", rCall)
+	defer rCall.Release()
+
+	// Set the operations processor going
+	queue = make(chan Operation)
+	go processOperations(queue)
+
+	// Set up the function plotter, and keep any plots using time (t) animated
+	initPlotInput()
+	initConsoleInput()
+	initAPI()
+	initCSVInput()
+	go animatePlot()
+
+	// Add some objects to the world space
+	initWorld()
+
+	// Add some transformation operations to the queue
+	for _, o := range demoOps {
+		queue <- o
+	}
+
+	// Keep the application running
+	done := make(chan struct{}, 0)
+	<-done
+}
+
+// (Re-)creates the scene graph, with the demo objects at their starting positions
+func initWorld() {
+	pointCounter = 1
+	meshes = make(map[string]*Mesh)
+	curves = make(map[string]*curve)
+	sceneRoot = &Node{Name: "world", Local: identityMatrix}
+	mesh1 := newMesh("object1", object1)
+	addNode(sceneRoot, "ob1", mesh1, 3.0, 3.0, 0.0)
+	addNode(sceneRoot, "ob1 copy", mesh1, -3.0, 3.0, 0.0)
+	addNode(sceneRoot, "ob2", newMesh("object2", object2), 3.0, -3.0, 1.0)
+	addNode(sceneRoot, "ob3", newMesh("object3", object3), -3.0, 0.0, -1.0)
+	updateWorldSpace()
+}
+
+// Initialise the canvas and input event handlers, for when we're running directly on the page
+func initPage() {
+	// Initialise canvas
 	canvasEl = doc.Call("getElementById", "mycanvas")
 	width = doc.Get("body").Get("clientWidth").Float()
 	height = doc.Get("body").Get("clientHeight").Float()
@@ -191,51 +282,22 @@ func main() {
 	// Set up the mouse click handler
 	cCall = js.NewCallback(clickHandler)
 	doc.Call("addEventListener", "mousedown", cCall)
-	defer cCall.Release()
 
 	// Set up the keypress handler
-	renderActive = atomic.NewBool(false)
 	kCall = js.NewCallback(keypressHandler)
 	doc.Call("addEventListener", "keydown", kCall)
-	defer kCall.Release()
 
 	// Set up the mouse move handler
 	mCall = js.NewCallback(moveHandler)
 	doc.Call("addEventListener", "mousemove", mCall)
-	defer mCall.Release()
 
-	// Set the frame renderer going
-	rCall = js.NewCallback(renderFrame)
-	js.Global().Call("requestAnimationFrame", rCall)
-	defer rCall.Release()
+	// Set up the mouse button release handler
+	uCall = js.NewCallback(mouseUpHandler)
+	doc.Call("addEventListener", "mouseup", uCall)
 
 	// Set up the mouse wheel handler
 	wCall = js.NewCallback(wheelHandler)
 	doc.Call("addEventListener", "wheel", wCall)
-	defer wCall.Release()
-
-	// Set the operations processor going
-	queue = make(chan Operation)
-	go processOperations(queue)
-
-	// Add some objects to the world space
-	worldSpace = make(map[string]Object, 1)
-	worldSpace["ob1"] = importObject(object1, 3.0, 3.0, 0.0)
-	worldSpace["ob1 copy"] = importObject(object1, -3.0, 3.0, 0.0)
-	worldSpace["ob2"] = importObject(object2, 3.0, -3.0, 1.0)
-	worldSpace["ob3"] = importObject(object3, -3.0, 0.0, -1.0)
-
-	// Add some transformation operations to the queue
-	queue <- Operation{op: ROTATE, t: 1000, f: 60, X: 0, Y: 0, Z: 90}
-	queue <- Operation{op: SCALE, t: 1000, f: 60, X: 2.0, Y: 2.0, Z: 2.0}
-	queue <- Operation{op: ROTATE, t: 1000, f: 60, X: 0, Y: 360, Z: 0}
-	queue <- Operation{op: SCALE, t: 1000, f: 60, X: 0.5, Y: 0.5, Z: 0.5}
-	queue <- Operation{op: ROTATE, t: 1000, f: 60, X: 45, Y: 0, Z: -240}
-	queue <- Operation{op: SCALE, t: 1000, f: 60, X: 1.5, Y: 1.5, Z: 1.52}
-
-	// Keep the application running
-	done := make(chan struct{}, 0)
-	<-done
 }
 
 // Simple mouse handler watching for people clicking on the source code link
@@ -252,58 +314,122 @@ func clickHandler(args []js.Value) {
 
 	// If the user clicks the source code URL area, open the URL
 	if clientX > graphWidth && clientY > (height-40) {
+		if inWorker {
+			// Workers can't open windows, so ask the page to do it for us
+			js.Global().Call("postMessage", map[string]interface{}{"type": "open", "url": "https://github.com/justinclift/wasmGraph1"})
+			return
+		}
 		w := js.Global().Call("open", "https://github.com/justinclift/wasmGraph1")
 		if w == js.Null() {
 			// Couldn't open a new window, so try loading directly in the existing one instead
 			doc.Set("location", "https://github.com/justinclift/wasmGraph1")
 		}
+		return
 	}
-}
 
-// Returns an object whose points have been transformed into 3D world space XYZ co-ordinates.  Also assigns a number
-// to each point
-func importObject(ob Object, x float64, y float64, z float64) (translatedObject Object) {
-	// X and Y translation matrix.  Translates the objects into the world space at the given X and Y co-ordinates
-	translateMatrix := matrix{
-		1, 0, 0, x,
-		0, 1, 0, y,
-		0, 0, 1, z,
-		0, 0, 0, 1,
+	// Clicks in the side panel are for the object inspector
+	if clientX > graphWidth {
+		inspectorClick(clientX, clientY)
+		return
+	}
+
+	// Clicking anywhere else finishes any edit in progress
+	if inspectorEdit != nil {
+		inspectorCommit()
+		return
+	}
+
+	// In edit mode, clicks pick the points, edges, and surfaces to edit instead
+	if editing {
+		editMouseDown(clientX, clientY, event.Get("shiftKey") == js.ValueOf(true))
+		return
+	}
+
+	// When measuring, clicks pick the points, edges, and surfaces to measure instead
+	if measuring != measureOff {
+		measureClick(clientX, clientY)
+		return
 	}
 
-	// Translate the points
-	var midX, midY, midZ float64
-	var pt Point
+	// Clicking on an object's point selects the object, and clicking on nothing clears the selection
+	name, _ := pickPoint(clientX, clientY)
+	selectObject(name)
+}
+
+// Returns a copy of the object, with a number assigned to each point.  The points are left in the object's own local
+// co-ordinates, as placing the object in the world is done by the transform of the scene node holding it
+func importObject(ob Object) (importedObject Object) {
 	for _, j := range ob.P {
-		pt = Point{
-			Num: pointCounter,
-			X:   (translateMatrix[0] * j.X) + (translateMatrix[1] * j.Y) + (translateMatrix[2] * j.Z) + (translateMatrix[3] * 1),   // 1st col, top
-			Y:   (translateMatrix[4] * j.X) + (translateMatrix[5] * j.Y) + (translateMatrix[6] * j.Z) + (translateMatrix[7] * 1),   // 1st col, upper middle
-			Z:   (translateMatrix[8] * j.X) + (translateMatrix[9] * j.Y) + (translateMatrix[10] * j.Z) + (translateMatrix[11] * 1), // 1st col, lower middle
-		}
-		translatedObject.P = append(translatedObject.P, pt)
-		midX += pt.X
-		midY += pt.Y
-		midZ += pt.Z
+		j.Num = pointCounter
+		importedObject.P = append(importedObject.P, j)
 		pointCounter++
 	}
 
 	// Determine the mid point for the object
-	numPts := float64(len(ob.P))
-	translatedObject.Mid.X = midX / numPts
-	translatedObject.Mid.Y = midY / numPts
-	translatedObject.Mid.Z = midZ / numPts
+	importedObject.Mid = midPoint(importedObject.P)
 
 	// Copy the colour, edge, and surface definitions across
-	translatedObject.C = ob.C
+	importedObject.C = ob.C
+	importedObject.HidePoints = ob.HidePoints
+	importedObject.HideLabels = ob.HideLabels
+	importedObject.Labels = ob.Labels
+	importedObject.PColour = ob.PColour
+	importedObject.PSize = ob.PSize
+	importedObject.Scalars = ob.Scalars
+	importedObject.ColourBy = ob.ColourBy
+	importedObject.ColourMap = ob.ColourMap
 	for _, j := range ob.E {
-		translatedObject.E = append(translatedObject.E, j)
+		importedObject.E = append(importedObject.E, j)
 	}
 	for _, j := range ob.S {
-		translatedObject.S = append(translatedObject.S, j)
+		importedObject.S = append(importedObject.S, j)
+	}
+	importedObject.Holes = ob.Holes
+	importedObject.T = triangulateObject(importedObject)
+	importedObject.AutoEdges = ob.AutoEdges || (len(ob.E) == 0 && len(ob.S) > 0)
+	importedObject.FeatureAngle = ob.FeatureAngle
+	if importedObject.AutoEdges {
+		importedObject.E = deriveEdges(importedObject)
+	}
+
+	return importedObject
+}
+
+// Returns the average of the given points
+func midPoint(pts []Point) (mid Point) {
+	if len(pts) == 0 {
+		return
+	}
+	for _, j := range pts {
+		mid.X += j.X
+		mid.Y += j.Y
+		mid.Z += j.Z
+	}
+	numPts := float64(len(pts))
+	mid.X /= numPts
+	mid.Y /= numPts
+	mid.Z /= numPts
+	return
+}
+
+// Returns the label for a point of an object.  This is the point's number, unless the object has its own labels
+func pointLabel(o Object, i int) string {
+	if i < len(o.Labels) && o.Labels[i] != "" {
+		return o.Labels[i]
 	}
+	return fmt.Sprintf("Point %d", o.P[i].Num)
+}
 
-	return translatedObject
+// Returns the colour and radius of the dot drawn for a point of an object
+func pointStyle(o Object, i int) (colour string, radius float64) {
+	colour, radius = theme.Point, 1
+	if i < len(o.PColour) && o.PColour[i] != "" {
+		colour = o.PColour[i]
+	}
+	if i < len(o.PSize) && o.PSize[i] > 0 {
+		radius = o.PSize[i]
+	}
+	return
 }
 
 // Simple keyboard handler for catching the arrow, WASD, and numpad keys
@@ -315,6 +441,136 @@ func keypressHandler(args []js.Value) {
 		fmt.Printf("Key is: %v\n", key)
 	}
 
+	// When a value in the inspector is being edited, the keys go to it instead
+	if inspectorEdit != nil {
+		inspectorKey(key)
+		return
+	}
+
+	// In edit mode, some keys edit the objects instead
+	if editing && editKey(key) {
+		return
+	}
+
+	// Exporting doesn't change the scene, so it's fine to do while an operation is in progress
+	switch key {
+	case "v":
+		// Export the current frame as SVG, including the side panel
+		exportSVG(true)
+		return
+	case "V":
+		// Export just the graph area as SVG
+		exportSVG(false)
+		return
+	case "c":
+		// Save a PNG screenshot of the graph area
+		screenshotPNG()
+		return
+	case "r":
+		// Start or stop recording frames as numbered PNGs
+		toggleRecording(recordPNG)
+		return
+	case "g":
+		// Start or stop recording frames as an animated GIF
+		toggleRecording(recordGIF)
+		return
+	case "x":
+		// Show or hide the world axes and orientation gizmo
+		showAxes = !showAxes
+		return
+	case "p":
+		// Show or hide the ground plane grid
+		showGroundGrid = !showGroundGrid
+		return
+	case "t":
+		// Swap between the light and dark themes
+		toggleTheme()
+		return
+	case "m":
+		// Switch objects coloured by a scalar to the next colour map
+		cycleColourMap()
+		return
+	case "b":
+		// Show or hide the bounding boxes and spheres
+		showBounds = !showBounds
+		return
+	case "k":
+		// Check the objects' surfaces for problems, highlighting any found
+		showTopology = !showTopology
+		return
+	case "e":
+		// Step through the feature angles, for objects with edges worked out from their surfaces
+		cycleFeatureAngle()
+		return
+	case "f":
+		// Zoom to fit everything in the scene
+		fitView()
+		return
+	case "F":
+		// Zoom to fit the selected object, or everything when nothing is selected
+		if selected != "" {
+			fitView(selected)
+		} else {
+			fitView()
+		}
+		return
+	case "l":
+		// Subdivide the selected object's surfaces into triangles, using Loop subdivision
+		queueMeshOp(LOOP)
+		return
+	case "C":
+		// Subdivide the selected object's surfaces into quads, using Catmull-Clark subdivision
+		queueMeshOp(CATMULLCLARK)
+		return
+	case "o":
+		// Smooth the selected object, by moving its points towards their neighbours
+		queueMeshOp(SMOOTH)
+		return
+	case "u":
+		// Replace the previously selected and selected objects with their union
+		queueCSG(UNION)
+		return
+	case "i":
+		// Replace the previously selected and selected objects with their intersection
+		queueCSG(INTERSECT)
+		return
+	case "q":
+		// Cut the selected object away from the previously selected one
+		queueCSG(DIFFERENCE)
+		return
+	case "M":
+		// Step through the measuring modes, which turns editing off
+		editing = false
+		cycleMeasureMode()
+		return
+	case "E":
+		// Turn edit mode on or off
+		toggleEditing()
+		return
+	case "Escape":
+		// Cancel the measurement in progress
+		clearMeasurePicks()
+		return
+	case "Backspace":
+		// Remove the most recent measurement
+		removeLastMeasurement()
+		return
+	case "H":
+		// Add the convex hull of the selected object's points
+		addSelectedHull()
+		return
+	case "L":
+		// Turn the simplified meshes for small objects on or off
+		toggleLOD()
+		return
+	case "R":
+		// Record the demo choreography from the start, as an animated GIF
+		if !renderActive.Load() && rec == nil {
+			go recordOperations(demoOps, recordGIF)
+		}
+		return
+	}
+
 	// Don't add operations if one is already in progress
 	stepSize := float64(25)
 	if !renderActive.Load() {
@@ -401,13 +657,72 @@ func moveHandler(args []js.Value) {
 	} else {
 		highLightSource = false
 	}
+
+	// Drag any point being edited along with the mouse
+	editMouseMove(clientX, clientY)
+}
+
+// Mouse button release handler, which finishes dragging points in edit mode
+func mouseUpHandler(args []js.Value) {
+	editMouseUp()
 }
 
 // Animates the transformation operations
 func processOperations(queue <-chan Operation) {
 	for i := range queue {
-		renderActive.Store(true)         // Mark rendering as now in progress
-		parts := i.f                     // Number of parts to break each transformation into
+		// Work out which part of the scene graph the operation applies to
+		target := sceneRoot
+		if i.target != "" {
+			target = findNode(sceneRoot, i.target)
+			if target == nil {
+				opText = fmt.Sprintf("Unknown object: %v", i.target)
+				operationFinished(i, "", errors.New(opText))
+				if rec != nil {
+					rec.operationDone()
+				}
+				continue
+			}
+		}
+
+		operationStarted(i)
+
+		// Subdivision and smoothing work out the new shape up front, then move the points there
+		var morph *meshMorph
+		if i.op == LOOP || i.op == CATMULLCLARK || i.op == SMOOTH {
+			var err error
+			morph, err = startMeshOp(target, i)
+			if err != nil {
+				opText = err.Error()
+				operationFinished(i, "", err)
+				if rec != nil {
+					rec.operationDone()
+				}
+				continue
+			}
+		}
+
+		// CSG operations happen all at once, as the result is a new object
+		if i.op == UNION || i.op == INTERSECT || i.op == DIFFERENCE {
+			name, err := applyCSG(i.op, i.target, i.other, i.X == 1)
+			if err != nil {
+				opText = err.Error()
+			} else {
+				opText = fmt.Sprintf("Created %v, the %v of %v and %v.", name, csgNames[i.op], i.target, i.other)
+			}
+			operationFinished(i, name, err)
+			if rec != nil {
+				rec.captureFrame()
+				rec.operationDone()
+			}
+			continue
+		}
+
+		renderActive.Store(true) // Mark rendering as now in progress
+		parts := i.f             // Number of parts to break each transformation into
+		if rec != nil {
+			// When recording, the frame rate is fixed by the recorder instead
+			parts = rec.frameCount(i.t)
+		}
 		transformMatrix = identityMatrix // Reset the transform matrix
 		switch i.op {
 		case ROTATE: // Rotate the objects in world space
@@ -442,39 +757,93 @@ func processOperations(queue <-chan Operation) {
 			// Translate (move) the objects in world space
 			transformMatrix = translate(transformMatrix, i.X/float64(parts), i.Y/float64(parts), i.Z/float64(parts))
 			opText = fmt.Sprintf("Translate (move). X: %0.2f Y: %0.2f Z: %0.2f", i.X, i.Y, i.Z)
+
+		case REVEAL:
+			opText = "Drawing curve."
+
+		case FIT:
+			opText = fmt.Sprintf("Zoom to fit. Scale: %0.2f", i.Z)
+
+		case LOOP:
+			opText = fmt.Sprintf("Loop subdivision. %d points", len(morph.to))
+
+		case CATMULLCLARK:
+			opText = fmt.Sprintf("Catmull-Clark subdivision. %d points", len(morph.to))
+
+		case SMOOTH:
+			opText = fmt.Sprintf("Smoothing. Passes: %0.0f Factor: %0.2f", math.Max(1, i.X), i.Y)
+		}
+		if i.target != "" {
+			opText = fmt.Sprintf("%v: %v", i.target, opText)
 		}
 
 		// Apply each transformation, one small part at a time (this gives the animation effect)
+		start := target.Local
 		timeSlice := time.Millisecond * time.Duration(i.t/parts)
 		for t := 0; t < int(parts); t++ {
-			time.Sleep(timeSlice)
-			for j, o := range worldSpace {
-				var newPoints []Point
-
-				// Transform each point of in the object
-				for _, j := range o.P {
-					newPoints = append(newPoints, transform(transformMatrix, j))
-				}
-				o.P = newPoints
-
-				// Transform the mid point of the object.  In theory, this should mean the mid point can always be used
-				// for a simple (not-cpu-intensive) way to sort the objects in Z depth order
-				o.Mid = transform(transformMatrix, o.Mid)
+			if rec != nil {
+				// Only pause briefly when recording, so the page stays responsive
+				time.Sleep(time.Millisecond)
+			} else {
+				time.Sleep(timeSlice)
+			}
 
-				// Update the object in world space
-				worldSpace[j] = o
+			// Apply the transformation to the target node, then work out the new world space positions
+			switch i.op {
+			case REVEAL:
+				revealCurve(target, float64(t+1)/float64(parts))
+			case FIT:
+				// Work from the starting transform each time, so the zoom grows evenly and ends up exact
+				f := float64(t+1) / float64(parts)
+				s := math.Pow(i.Z, f)
+				target.Local = scale(translate(start, -i.X*f, -i.Y*f, 0), s, s, s)
+			case LOOP, CATMULLCLARK, SMOOTH:
+				morph.step(float64(t+1) / float64(parts))
+			default:
+				applyTransform(target, i.op, transformMatrix)
+			}
+			updateWorldSpace()
+			if rec != nil {
+				rec.captureFrame()
 			}
 		}
 		renderActive.Store(false)
 		opText = "Complete."
+		operationFinished(i, "", nil)
+		if rec != nil {
+			rec.operationDone()
+		}
+	}
+}
+
+// Returns the names of the objects in the given world space, sorted by mid point Z depth order
+func depthOrder(ws map[string]Object) (order paintOrderSlice) {
+	for i, j := range ws {
+		order = append(order, paintOrder{name: i, midZ: j.Mid.Z})
 	}
+	sort.Sort(order)
+	return
 }
 
-// Renders one frame of the animation
+// Renders one frame of the animation, then schedules the next one
 func renderFrame(args []js.Value) {
-	// Handle window resizing
-	curBodyW := doc.Get("body").Get("clientWidth").Float()
-	curBodyH := doc.Get("body").Get("clientHeight").Float()
+	drawFrame()
+	if len(args) > 0 {
+		apiEmit("frame", map[string]interface{}{"time": args[0].Float()})
+	}
+
+	// Schedule the next frame render call
+	js.Global().Call("requestAnimationFrame", rCall)
+}
+
+// Draws the current state of the world space onto the canvas
+func drawFrame() {
+	// Handle window resizing.  When running in a worker, the page sends us its new size instead
+	curBodyW, curBodyH := workerWidth, workerHeight
+	if !inWorker {
+		curBodyW = doc.Get("body").Get("clientWidth").Float()
+		curBodyH = doc.Get("body").Get("clientHeight").Float()
+	}
 	if curBodyW != width || curBodyH != height {
 		width, height = curBodyW, curBodyH
 		canvasEl.Set("width", width)
@@ -492,7 +861,7 @@ func renderFrame(args []js.Value) {
 	centerY := graphHeight / 2
 
 	// Clear the background
-	ctx.Set("fillStyle", "white")
+	ctx.Set("fillStyle", theme.Background)
 	ctx.Call("fillRect", 0, 0, width, height)
 
 	// Save the current graphics state - no clip region currently defined - as the default
@@ -508,7 +877,7 @@ func renderFrame(args []js.Value) {
 
 	// Draw grid lines
 	step := math.Min(width, height) / 30
-	ctx.Set("strokeStyle", "rgb(220, 220, 220)")
+	ctx.Set("strokeStyle", theme.Grid)
 	ctx.Call("setLineDash", []interface{}{1, 3})
 	for i := left; i < graphWidth-step; i += step {
 		// Vertical dashed lines
@@ -525,40 +894,70 @@ func renderFrame(args []js.Value) {
 		ctx.Call("stroke")
 	}
 
-	// Sort the objects by mid point Z depth order
-	var order paintOrderSlice
-	for i, j := range worldSpace {
-		order = append(order, paintOrder{name: i, midZ: j.Mid.Z})
+	// Draw the world axes, ground grid, and orientation gizmo
+	lines, labels := worldGuides(centerX, centerY, step, left, graphHeight)
+	drawGuideLines(lines)
+	for _, l := range labels {
+		ctx.Set("fillStyle", l.colour)
+		ctx.Set("font", l.font)
+		ctx.Call("fillText", l.text, l.x, l.y)
 	}
-	sort.Sort(paintOrderSlice(order))
+
+	// Sort the objects by mid point Z depth order
+	order := depthOrder(worldSpace)
 
 	// Draw the objects, in Z depth order
 	var pointX, pointY float64
-	var pointNum int
 	numWld := len(worldSpace)
 	for i := 0; i < numWld; i++ {
-		o := worldSpace[order[i].name]
+		// Objects too small on screen to show all their detail are drawn using a simplified mesh
+		o := lodObject(worldNodes[order[i].name], worldSpace[order[i].name], step)
 
-		// Draw the surfaces
+		// Draw the surfaces, furthest away first.  Each surface is filled as one path made of its triangles, so there
+		// are no seams between them.  Objects coloured by a scalar get a gradient across each triangle instead
 		ctx.Set("fillStyle", o.C)
-		for _, l := range o.S {
-			for m, n := range l {
-				pointX = o.P[n].X
-				pointY = o.P[n].Y
-				if m == 0 {
+		vals, lo, hi, cm, scalar := objectScalars(o)
+		tris := surfaceTriangles(o)
+		for _, si := range surfaceOrder(o) {
+			if !scalar {
+				ctx.Call("beginPath")
+			}
+			for _, t := range tris[si] {
+				if scalar {
+					x1, y1, x2, y2, stops := surfaceGradient(o, Surface{t.A, t.B, t.C}, vals, lo, hi, cm)
+					if len(stops) == 1 {
+						// Gradients with no length paint nothing, so use the colour directly
+						ctx.Set("fillStyle", stops[0].colour)
+					} else {
+						grad := ctx.Call("createLinearGradient", centerX+(x1*step), centerY-(y1*step), centerX+(x2*step), centerY-(y2*step))
+						for _, st := range stops {
+							grad.Call("addColorStop", st.pos, st.colour)
+						}
+						ctx.Set("fillStyle", grad)
+					}
 					ctx.Call("beginPath")
-					ctx.Call("moveTo", centerX+(pointX*step), centerY+((pointY*step)*-1))
-				} else {
-					ctx.Call("lineTo", centerX+(pointX*step), centerY+((pointY*step)*-1))
+				}
+				for m, n := range []int{t.A, t.B, t.C} {
+					pointX = o.P[n].X
+					pointY = o.P[n].Y
+					if m == 0 {
+						ctx.Call("moveTo", centerX+(pointX*step), centerY+((pointY*step)*-1))
+					} else {
+						ctx.Call("lineTo", centerX+(pointX*step), centerY+((pointY*step)*-1))
+					}
+				}
+				ctx.Call("closePath")
+				if scalar {
+					ctx.Call("fill")
 				}
 			}
-			ctx.Call("closePath")
-			ctx.Call("fill")
+			if !scalar {
+				ctx.Call("fill")
+			}
 		}
 
 		// Draw the edges
-		ctx.Set("strokeStyle", "black")
-		ctx.Set("fillStyle", "black")
+		ctx.Set("strokeStyle", theme.Edge)
 		ctx.Set("lineWidth", "1")
 		ctx.Call("setLineDash", []interface{}{2, 4})
 		var point1X, point1Y, point2X, point2Y float64
@@ -575,21 +974,50 @@ func renderFrame(args []js.Value) {
 
 		// Draw the points on the graph
 		ctx.Call("setLineDash", []interface{}{})
+		if o.HidePoints {
+			continue
+		}
+		ctx.Set("font", theme.PointFont)
+		if o.PColour == nil {
+			o.PColour = scalarPointColours(o)
+		}
 		var px, py float64
-		for _, l := range o.P {
+		for k, l := range o.P {
 			// Draw a dot for the point
 			px = centerX + (l.X * step)
 			py = centerY + ((l.Y * step) * -1)
+			colour, radius := pointStyle(o, k)
+			ctx.Set("fillStyle", colour)
 			ctx.Call("beginPath")
-			ctx.Call("arc", px, py, 1, 0, 2*math.Pi)
+			ctx.Call("arc", px, py, radius, 0, 2*math.Pi)
 			ctx.Call("fill")
 
 			// Label the point on the graph
-			ctx.Set("font", "12px sans-serif")
-			ctx.Call("fillText", fmt.Sprintf("Point %d", l.Num), px+5, py+15)
+			if o.HideLabels {
+				continue
+			}
+			ctx.Set("fillStyle", theme.PointLabel)
+			ctx.Call("fillText", pointLabel(o, k), px+5+radius-1, py+15)
 		}
 	}
 
+	// Draw the bounding boxes and spheres, and any surface problems, over the top of the objects
+	drawGuideLines(boundsGuides(worldSpace, centerX, centerY, step))
+	drawGuideLines(topologyGuides(worldSpace, centerX, centerY, step))
+	drawGuideLines(editGuides(worldSpace, centerX, centerY, step))
+
+	// Draw the measurements
+	lines, labels = measureGuides(worldSpace, centerX, centerY, step)
+	drawGuideLines(lines)
+	for _, l := range labels {
+		ctx.Set("fillStyle", l.colour)
+		ctx.Set("font", l.font)
+		ctx.Call("fillText", l.text, l.x, l.y)
+	}
+
+	// Draw the recent console output, above the console input
+	drawConsole(border+12, graphHeight-24)
+
 	// Set the clip region so drawing only occurs in the display area
 	ctx.Call("restore")
 	ctx.Call("save")
@@ -602,57 +1030,64 @@ func renderFrame(args []js.Value) {
 
 	// Draw the text describing the current operation
 	textY := top + 20
-	ctx.Set("fillStyle", "black")
-	ctx.Set("font", "bold 14px serif")
+	ctx.Set("fillStyle", theme.Heading)
+	ctx.Set("font", theme.HeadingFont)
 	ctx.Call("fillText", "Operation:", graphWidth+20, textY)
 	textY += 20
-	ctx.Set("font", "14px sans-serif")
+	ctx.Set("fillStyle", theme.Text)
+	ctx.Set("font", theme.TextFont)
 	ctx.Call("fillText", opText, graphWidth+20, textY)
 	textY += 30
 
+	// Let the user know when frames are being recorded
+	if rec != nil {
+		ctx.Set("fillStyle", theme.Recording)
+		ctx.Call("fillText", fmt.Sprintf("Recording: %d frames", rec.count), graphWidth+20, textY)
+		textY += 30
+	}
+
 	// Add the help text about control keys and mouse zoom
-	ctx.Set("fillStyle", "blue")
-	ctx.Set("font", "14px sans-serif")
+	ctx.Set("fillStyle", theme.Help)
+	ctx.Set("font", theme.TextFont)
 	ctx.Call("fillText", "Use wasd/numpad keys to rotate,", graphWidth+20, textY)
 	textY += 20
 	ctx.Call("fillText", "mouse wheel to zoom.", graphWidth+20, textY)
+	textY += 20
+	ctx.Call("fillText", "Click values below to edit them.", graphWidth+20, textY)
 	textY += 10
 
-	// Add the point co-ordinate information
-	ctx.Set("fillStyle", "black")
-	for _, o := range worldSpace {
-		for _, l := range o.P {
-			// Draw darker coloured legend text
-			ctx.Set("font", "bold 14px serif")
-			ctx.Call("fillText", fmt.Sprintf("Point %d:", l.Num), graphWidth+20, textY+float64(l.Num*25))
-
-			// Draw lighter coloured legend text
-			ctx.Set("font", "12px sans-serif")
-			ctx.Call("fillText", fmt.Sprintf("(%0.1f, %0.1f, %0.1f)", l.X, l.Y, l.Z), graphWidth+100, textY+float64(l.Num*25))
-			pointNum++
-		}
-	}
+	// Add the colour bar legends, for objects coloured by a scalar
+	textY = drawColourBars(textY)
+
+	// Add the results of the surface checks, when they're turned on
+	textY = drawTopology(textY)
+
+	// Add the measurements, when there are any
+	textY = drawMeasurements(textY)
+
+	// Add the object inspector, showing the point co-ordinates
+	drawInspector(textY, graphHeight-55)
 
 	// Clear the source code link area
-	ctx.Set("fillStyle", "white")
+	ctx.Set("fillStyle", theme.Background)
 	ctx.Call("fillRect", graphWidth+1, graphHeight-55, width, height)
 
 	// Add the URL to the source code
-	ctx.Set("fillStyle", "black")
-	ctx.Set("font", "bold 14px serif")
+	ctx.Set("fillStyle", theme.Heading)
+	ctx.Set("font", theme.HeadingFont)
 	ctx.Call("fillText", "Source code:", graphWidth+20, graphHeight-35)
-	ctx.Set("fillStyle", "blue")
+	ctx.Set("fillStyle", theme.Link)
 	if highLightSource == true {
-		ctx.Set("font", "bold 12px sans-serif")
+		ctx.Set("font", theme.LinkHoverFont)
 	} else {
-		ctx.Set("font", "12px sans-serif")
+		ctx.Set("font", theme.LinkFont)
 	}
 	ctx.Call("fillText", "https://github.com/justinclift/wasmGraph1", graphWidth+20, graphHeight-15)
 
 	// Draw a border around the graph area
 	ctx.Call("setLineDash", []interface{}{})
 	ctx.Set("lineWidth", "2")
-	ctx.Set("strokeStyle", "white")
+	ctx.Set("strokeStyle", theme.Background)
 	ctx.Call("beginPath")
 	ctx.Call("moveTo", 0, 0)
 	ctx.Call("lineTo", width, 0)
@@ -661,7 +1096,7 @@ func renderFrame(args []js.Value) {
 	ctx.Call("closePath")
 	ctx.Call("stroke")
 	ctx.Set("lineWidth", "2")
-	ctx.Set("strokeStyle", "black")
+	ctx.Set("strokeStyle", theme.GraphBorder)
 	ctx.Call("beginPath")
 	ctx.Call("moveTo", border, border)
 	ctx.Call("lineTo", graphWidth, border)
@@ -672,9 +1107,19 @@ func renderFrame(args []js.Value) {
 
 	// Restore the default graphics state (eg no clip region)
 	ctx.Call("restore")
+}
 
-	// Schedule the next frame render call
-	js.Global().Call("requestAnimationFrame", rCall)
+// Draws lines which have already been projected onto the graph
+func drawGuideLines(lines []guideLine) {
+	ctx.Call("setLineDash", []interface{}{})
+	for _, l := range lines {
+		ctx.Set("strokeStyle", l.colour)
+		ctx.Set("lineWidth", l.width)
+		ctx.Call("beginPath")
+		ctx.Call("moveTo", l.x1, l.y1)
+		ctx.Call("lineTo", l.x2, l.y2)
+		ctx.Call("stroke")
+	}
 }
 
 // Rotates a transformation matrix around the X axis by the given degrees
@@ -766,6 +1211,12 @@ func translate(m matrix, translateX float64, translateY float64, translateZ floa
 func wheelHandler(args []js.Value) {
 	event := args[0]
 	wheelDelta := event.Get("deltaY").Float()
+
+	// Scroll the inspector when the mouse is over the side panel
+	if event.Get("clientX").Float() > graphWidth {
+		inspectorScrollBy(wheelDelta)
+		return
+	}
 	scaleSize := 1 + (wheelDelta / 5)
 	if debug {
 		fmt.Printf("Wheel delta: %v, scaleSize: %v\n", wheelDelta, scaleSize)
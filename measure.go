@@ -0,0 +1,490 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// The kinds of measurement the M key steps through
+type measureMode int
+
+const (
+	measureOff      measureMode = iota
+	measureDistance             // Distance between two points
+	measureAngle                // Angle at the middle of three points, or between two edges
+	measureArea                 // Area of a surface, along with the area and volume of its object
+)
+
+// What a measurement pick refers to
+type pickKind int
+
+const (
+	pickedPoint pickKind = iota
+	pickedEdge
+	pickedSurface
+)
+
+// A point, edge, or surface of an object picked for measuring or editing.  These are kept by index rather than
+// position, so measurements follow the objects as they move
+type elementPick struct {
+	object string
+	kind   pickKind
+	index  int
+}
+
+// A finished measurement, drawn on the graph as a dimension annotation
+type measurement struct {
+	mode  measureMode
+	picks []elementPick
+}
+
+var (
+	measuring     = measureOff  // The kind of measurement clicks on the graph make.  Clicks select objects when off
+	measurePicks  []elementPick // The picks made so far for the measurement in progress
+	measurements  []measurement // Finished measurements
+	measureNames  = []string{"", "distance", "angle", "area"}
+	measureTick   = 6.0  // Length of the ticks at the ends of a distance annotation, in pixels
+	measureArcLen = 24.0 // Radius of the arc marking an angle, in pixels
+)
+
+// Steps on to the next measuring mode, clearing any measurement in progress
+func cycleMeasureMode() {
+	measuring = (measuring + 1) % measureMode(len(measureNames))
+	measurePicks = nil
+	switch measuring {
+	case measureOff:
+		opText = "Measuring off."
+	case measureDistance:
+		opText = "Measure distance: click on two points."
+	case measureAngle:
+		opText = "Measure angle: click on three points, or two edges."
+	case measureArea:
+		opText = "Measure area: click on a surface."
+	}
+}
+
+// Returns the point of an object, in world co-ordinates (without the view transform).  The bool is false if the object
+// or point no longer exists
+func measurePoint(object string, idx int) (Point, bool) {
+	o, ok := worldSpace[object]
+	if !ok || idx < 0 || idx >= len(o.P) {
+		return Point{}, false
+	}
+	inv, ok := invertMatrix(sceneMatrix)
+	if !ok {
+		return Point{}, false
+	}
+	return transform(inv, o.P[idx]), validPoint(o.P[idx])
+}
+
+// Returns the two end points of an edge of an object, in world co-ordinates
+func measureEdge(object string, idx int) (Point, Point, bool) {
+	o, ok := worldSpace[object]
+	if !ok || idx < 0 || idx >= len(o.E) {
+		return Point{}, Point{}, false
+	}
+	a, okA := measurePoint(object, o.E[idx][0])
+	b, okB := measurePoint(object, o.E[idx][1])
+	return a, b, okA && okB
+}
+
+// Returns the index of the edge of an object closest to the given canvas position, along with the object's name.
+// Returns an empty name when no edge is within pickRadius
+func pickEdge(x float64, y float64) (name string, idx int) {
+	best := pickRadius
+	for n, o := range worldSpace {
+		for i, e := range o.E {
+			if e[0] >= len(o.P) || e[1] >= len(o.P) {
+				continue
+			}
+			x1, y1 := screenPos(o.P[e[0]])
+			x2, y2 := screenPos(o.P[e[1]])
+			dx, dy := x2-x1, y2-y1
+			t := 0.0
+			if l2 := dx*dx + dy*dy; l2 > 0 {
+				t = math.Max(0, math.Min(1, ((x-x1)*dx+(y-y1)*dy)/l2))
+			}
+			if d := math.Hypot(x1+dx*t-x, y1+dy*t-y); d < best {
+				name, idx, best = n, i, d
+			}
+		}
+	}
+	return
+}
+
+// Returns the index of the surface under the given canvas position, along with its object's name.  When surfaces
+// overlap, the one nearest the viewer wins.  Returns an empty name when there's no surface there
+func pickSurface(x float64, y float64) (name string, idx int) {
+	bestZ := math.Inf(-1)
+	for n, o := range worldSpace {
+		for _, t := range o.T {
+			var f [3]flatPoint
+			z := 0.0
+			for j, p := range []int{t.A, t.B, t.C} {
+				sx, sy := screenPos(o.P[p])
+				f[j] = flatPoint{u: sx, v: sy}
+				z += o.P[p].Z / 3
+			}
+			if inTriangle(f[0], f[1], f[2], flatPoint{u: x, v: y}) && z > bestZ {
+				name, idx, bestZ = n, t.S, z
+			}
+		}
+	}
+	return
+}
+
+// Handles a click on the graph while measuring, adding to the measurement in progress and finishing it once there are
+// enough picks
+func measureClick(x float64, y float64) {
+	var p elementPick
+	switch measuring {
+	case measureDistance:
+		name, idx := pickPoint(x, y)
+		if name == "" {
+			opText = "Measure distance: click on a point."
+			return
+		}
+		p = elementPick{object: name, kind: pickedPoint, index: idx}
+	case measureAngle:
+		// Points are tried first, as they sit on the ends of edges.  The picks all need to be the same kind
+		name, idx := pickPoint(x, y)
+		kind := pickedPoint
+		if name == "" || (len(measurePicks) > 0 && measurePicks[0].kind == pickedEdge) {
+			name, idx = pickEdge(x, y)
+			kind = pickedEdge
+		}
+		if name == "" || (len(measurePicks) > 0 && measurePicks[0].kind != kind) {
+			opText = "Measure angle: click on three points, or two edges."
+			return
+		}
+		p = elementPick{object: name, kind: kind, index: idx}
+	case measureArea:
+		name, idx := pickSurface(x, y)
+		if name == "" {
+			opText = "Measure area: click on a surface."
+			return
+		}
+		p = elementPick{object: name, kind: pickedSurface, index: idx}
+	}
+	measurePicks = append(measurePicks, p)
+
+	need := 1
+	switch {
+	case measuring == measureDistance:
+		need = 2
+	case measuring == measureAngle && p.kind == pickedPoint:
+		need = 3
+	case measuring == measureAngle:
+		need = 2
+	}
+	if len(measurePicks) < need {
+		opText = fmt.Sprintf("Measure %v: %d of %d picked.", measureNames[measuring], len(measurePicks), need)
+		return
+	}
+	m := measurement{mode: measuring, picks: measurePicks}
+	measurePicks = nil
+	measurements = append(measurements, m)
+	if v, ok := m.value(); ok {
+		opText = fmt.Sprintf("Measured %v: %v", measureNames[m.mode], v)
+	}
+}
+
+// Clears the measurement in progress
+func clearMeasurePicks() {
+	if len(measurePicks) > 0 {
+		measurePicks = nil
+		opText = "Measurement cancelled."
+	}
+}
+
+// Removes the most recent measurement
+func removeLastMeasurement() {
+	if len(measurements) > 0 {
+		measurements = measurements[:len(measurements)-1]
+		opText = "Measurement removed."
+	}
+}
+
+// Returns the area of the given triangles of an object, in world co-ordinates
+func trianglesArea(pts []Point, tris []Triangle) (a float64) {
+	for _, t := range tris {
+		n := triangleNormal(pts[t.A], pts[t.B], pts[t.C])
+		a += math.Sqrt(dot(n, n)) / 2
+	}
+	return
+}
+
+// Returns the total surface area of an object, along with its enclosed volume.  The bool is false when the object
+// isn't closed, so it has no volume
+func areaAndVolume(o Object) (area float64, volume float64, closed bool) {
+	area = trianglesArea(o.P, triangulateObject(o))
+	or := orientSurfaces(o)
+	if !analyseTopology(or).closed() {
+		return area, 0, false
+	}
+	return area, math.Abs(signedVolume(or)), true
+}
+
+// The areas and volume of an object, kept between frames as they take a while to work out
+type areaCache struct {
+	mesh     *Mesh
+	version  int      // The mesh version they were worked out for
+	locals   []matrix // The transforms of the object's node and its parents, up to the scene root
+	surfaces []float64
+	area     float64
+	volume   float64
+	closed   bool
+}
+
+var areaCaches = make(map[string]*areaCache) // By object name
+
+// Returns the areas of an object's surfaces, along with its total area and volume (if it's closed), in world units.
+// These are only worked out again when the object's mesh or position in the scene changes.  Moving the view doesn't
+// change them.  The bool is false if there's no object with the name
+func objectAreas(name string) (*areaCache, bool) {
+	n := findNode(sceneRoot, name)
+	if n == nil || n.Mesh == nil {
+		return nil, false
+	}
+	var locals []matrix
+	for p := n; p != nil && p != sceneRoot; p = p.Parent {
+		locals = append(locals, append(matrix(nil), p.Local...))
+	}
+	if c := areaCaches[name]; c != nil && c.mesh == n.Mesh && c.version == n.Mesh.version && sameMatrices(c.locals, locals) {
+		return c, true
+	}
+	o, ok := measureObject(name)
+	if !ok {
+		return nil, false
+	}
+	c := &areaCache{mesh: n.Mesh, version: n.Mesh.version, locals: locals}
+	for _, tris := range surfaceTriangles(o) {
+		c.surfaces = append(c.surfaces, trianglesArea(o.P, tris))
+	}
+	c.area, c.volume, c.closed = areaAndVolume(o)
+	areaCaches[name] = c
+	return c, true
+}
+
+// Returns whether two lists of matrices are exactly the same
+func sameMatrices(a []matrix, b []matrix) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if a[i][j] != b[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Returns the world space version of an object, without the view transform, so measurements are in world units
+func measureObject(name string) (Object, bool) {
+	o, ok := worldSpace[name]
+	if !ok {
+		return o, false
+	}
+	o.P = unviewedPoints(o)
+	return o, o.P != nil
+}
+
+// Returns the measured value as text.  The bool is false if the measurement no longer makes sense (eg an object it
+// refers to has been removed)
+func (m measurement) value() (string, bool) {
+	switch {
+	case m.mode == measureDistance:
+		a, okA := measurePoint(m.picks[0].object, m.picks[0].index)
+		b, okB := measurePoint(m.picks[1].object, m.picks[1].index)
+		return fmt.Sprintf("%.3g", distance(a, b)), okA && okB
+
+	case m.mode == measureAngle && m.picks[0].kind == pickedPoint:
+		a, okA := measurePoint(m.picks[0].object, m.picks[0].index)
+		b, okB := measurePoint(m.picks[1].object, m.picks[1].index)
+		c, okC := measurePoint(m.picks[2].object, m.picks[2].index)
+		u := Point{X: a.X - b.X, Y: a.Y - b.Y, Z: a.Z - b.Z}
+		v := Point{X: c.X - b.X, Y: c.Y - b.Y, Z: c.Z - b.Z}
+		return fmt.Sprintf("%.1f°", angleBetween(u, v)), okA && okB && okC
+
+	case m.mode == measureAngle:
+		a1, a2, okA := measureEdge(m.picks[0].object, m.picks[0].index)
+		b1, b2, okB := measureEdge(m.picks[1].object, m.picks[1].index)
+		u := Point{X: a2.X - a1.X, Y: a2.Y - a1.Y, Z: a2.Z - a1.Z}
+		v := Point{X: b2.X - b1.X, Y: b2.Y - b1.Y, Z: b2.Z - b1.Z}
+		return fmt.Sprintf("%.1f°", angleBetween(u, v)), okA && okB
+
+	case m.mode == measureArea:
+		c, ok := objectAreas(m.picks[0].object)
+		s := m.picks[0].index
+		if !ok || s < 0 || s >= len(c.surfaces) {
+			return "", false
+		}
+		return fmt.Sprintf("%.3g", c.surfaces[s]), true
+	}
+	return "", false
+}
+
+// Returns a description of a measurement for the side panel
+func (m measurement) String() string {
+	v, ok := m.value()
+	if !ok {
+		return "(no longer valid)"
+	}
+	desc := func(p elementPick) string {
+		switch p.kind {
+		case pickedPoint:
+			return fmt.Sprintf("%v P%d", p.object, p.index)
+		case pickedEdge:
+			return fmt.Sprintf("%v E%d", p.object, p.index)
+		}
+		return fmt.Sprintf("%v S%d", p.object, p.index)
+	}
+	switch {
+	case m.mode == measureDistance:
+		return fmt.Sprintf("%v to %v: %v", desc(m.picks[0]), desc(m.picks[1]), v)
+	case m.mode == measureAngle && len(m.picks) == 3:
+		return fmt.Sprintf("%v, %v, %v: %v", desc(m.picks[0]), desc(m.picks[1]), desc(m.picks[2]), v)
+	case m.mode == measureAngle:
+		return fmt.Sprintf("%v to %v: %v", desc(m.picks[0]), desc(m.picks[1]), v)
+	}
+	c, _ := objectAreas(m.picks[0].object)
+	s := fmt.Sprintf("%v area %v, total %.3g", desc(m.picks[0]), v, c.area)
+	if c.closed {
+		s += fmt.Sprintf(", volume %.3g", c.volume)
+	}
+	return s
+}
+
+// Returns the dimension annotations for the measurements, and markers for the picks of the one in progress, projected
+// onto the graph
+func measureGuides(ws map[string]Object, centerX float64, centerY float64, step float64) (lines []guideLine, labels []guideLabel) {
+	screen := func(object string, idx int) (float64, float64, bool) {
+		o, ok := ws[object]
+		if !ok || idx < 0 || idx >= len(o.P) {
+			return 0, 0, false
+		}
+		return centerX + (o.P[idx].X * step), centerY - (o.P[idx].Y * step), true
+	}
+	addLine := func(x1 float64, y1 float64, x2 float64, y2 float64, w float64) {
+		lines = append(lines, guideLine{x1: x1, y1: y1, x2: x2, y2: y2, colour: theme.Measure, width: w})
+	}
+	addLabel := func(x float64, y float64, text string) {
+		labels = append(labels, guideLabel{x: x + 4, y: y - 4, colour: theme.Measure, font: theme.MeasureFont, text: text})
+	}
+	edge := func(object string, idx int, w float64) (float64, float64, bool) {
+		o, ok := ws[object]
+		if !ok || idx < 0 || idx >= len(o.E) {
+			return 0, 0, false
+		}
+		x1, y1, ok1 := screen(object, o.E[idx][0])
+		x2, y2, ok2 := screen(object, o.E[idx][1])
+		if !ok1 || !ok2 {
+			return 0, 0, false
+		}
+		addLine(x1, y1, x2, y2, w)
+		return (x1 + x2) / 2, (y1 + y2) / 2, true
+	}
+
+	for _, m := range measurements {
+		v, ok := m.value()
+		if !ok {
+			continue
+		}
+		switch {
+		case m.mode == measureDistance:
+			// A dimension line, with ticks across each end
+			x1, y1, _ := screen(m.picks[0].object, m.picks[0].index)
+			x2, y2, _ := screen(m.picks[1].object, m.picks[1].index)
+			addLine(x1, y1, x2, y2, 1)
+			if l := math.Hypot(x2-x1, y2-y1); l > 0 {
+				tx, ty := -(y2-y1)/l*measureTick, (x2-x1)/l*measureTick
+				addLine(x1-tx, y1-ty, x1+tx, y1+ty, 1)
+				addLine(x2-tx, y2-ty, x2+tx, y2+ty, 1)
+			}
+			addLabel((x1+x2)/2, (y1+y2)/2, v)
+
+		case m.mode == measureAngle && m.picks[0].kind == pickedPoint:
+			// Lines out from the middle point, with an arc between them
+			x1, y1, _ := screen(m.picks[0].object, m.picks[0].index)
+			bx, by, _ := screen(m.picks[1].object, m.picks[1].index)
+			x2, y2, _ := screen(m.picks[2].object, m.picks[2].index)
+			addLine(bx, by, x1, y1, 1)
+			addLine(bx, by, x2, y2, 1)
+			a1, a2 := math.Atan2(y1-by, x1-bx), math.Atan2(y2-by, x2-bx)
+			sweep := math.Remainder(a2-a1, 2*math.Pi)
+			const parts = 12
+			for i := 0; i < parts; i++ {
+				s, e := a1+sweep*float64(i)/parts, a1+sweep*float64(i+1)/parts
+				addLine(bx+math.Cos(s)*measureArcLen, by+math.Sin(s)*measureArcLen, bx+math.Cos(e)*measureArcLen,
+					by+math.Sin(e)*measureArcLen, 1)
+			}
+			mid := a1 + sweep/2
+			addLabel(bx+math.Cos(mid)*measureArcLen, by+math.Sin(mid)*measureArcLen, v)
+
+		case m.mode == measureAngle:
+			// Both edges highlighted, with the angle between them
+			mx1, my1, _ := edge(m.picks[0].object, m.picks[0].index, 2)
+			mx2, my2, _ := edge(m.picks[1].object, m.picks[1].index, 2)
+			addLine(mx1, my1, mx2, my2, 1)
+			addLabel((mx1+mx2)/2, (my1+my2)/2, v)
+
+		case m.mode == measureArea:
+			// The surface outlined, with its area in the middle
+			o := ws[m.picks[0].object]
+			if m.picks[0].index >= len(o.S) {
+				continue
+			}
+			s := o.S[m.picks[0].index]
+			var cx, cy float64
+			for j, p := range s {
+				x1, y1, _ := screen(m.picks[0].object, p)
+				x2, y2, _ := screen(m.picks[0].object, s[(j+1)%len(s)])
+				addLine(x1, y1, x2, y2, 2)
+				cx += x1 / float64(len(s))
+				cy += y1 / float64(len(s))
+			}
+			addLabel(cx, cy, v)
+		}
+	}
+
+	// Mark the picks so far of the measurement in progress
+	for _, p := range measurePicks {
+		switch p.kind {
+		case pickedPoint:
+			if x, y, ok := screen(p.object, p.index); ok {
+				addLine(x-measureTick, y-measureTick, x+measureTick, y+measureTick, 2)
+				addLine(x-measureTick, y+measureTick, x+measureTick, y-measureTick, 2)
+			}
+		case pickedEdge:
+			edge(p.object, p.index, 3)
+		}
+	}
+	return
+}
+
+// Draws the measurements in the side panel, starting at the given height.  Returns the height below them
+func drawMeasurements(textY float64) float64 {
+	if measuring == measureOff && len(measurements) == 0 {
+		return textY
+	}
+	textY += 20
+	ctx.Set("fillStyle", theme.Heading)
+	ctx.Set("font", theme.HeadingFont)
+	heading := "Measurements:"
+	if measuring != measureOff {
+		heading = fmt.Sprintf("Measurements (%v):", measureNames[measuring])
+	}
+	ctx.Call("fillText", heading, graphWidth+20, textY)
+	ctx.Set("fillStyle", theme.Text)
+	ctx.Set("font", theme.ValueFont)
+	for _, m := range measurements {
+		textY += 16
+		ctx.Call("fillText", m.String(), graphWidth+20, textY)
+	}
+	return textY
+}
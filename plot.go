@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"syscall/js"
+	"time"
+)
+
+const plotName = "plot" // Name of the scene node holding the function plot
+
+var (
+	plotXMin, plotXMax = -5.0, 5.0 // The X range the function is plotted over
+	plotYMin, plotYMax = -5.0, 5.0 // The Y range the function is plotted over
+	plotDivs           = 30        // Number of divisions along each side of the plot grid
+	plotColour         = "plum"
+	plotExpr           expr // The function being plotted.  nil when there isn't one
+	plotStart          time.Time
+	plotCall           js.Callback
+	plotChangeCall     js.Callback
+)
+
+// Returns a grid object for the surface z = f(x, y), sampled over the given X and Y ranges.  Surfaces touching a
+// point where the function has no valid value (eg sqrt of a negative number) are left out.  The surface is coloured by
+// its height
+func plotSurface(e expr, xMin float64, xMax float64, yMin float64, yMax float64, xDivs int, yDivs int, t float64, colour string) (o Object) {
+	xDivs = maxInt(xDivs, 1)
+	yDivs = maxInt(yDivs, 1)
+	o.C = colour
+	o.HidePoints = true
+	o.ColourBy = "z"
+	o.ColourMap = colourMapName
+	v := exprVars{t: t}
+	valid := make([]bool, 0, (xDivs+1)*(yDivs+1))
+	heights := make([]float64, 0, (xDivs+1)*(yDivs+1))
+	for j := 0; j <= yDivs; j++ {
+		v.y = yMin + ((yMax - yMin) * float64(j) / float64(yDivs))
+		for i := 0; i <= xDivs; i++ {
+			v.x = xMin + ((xMax - xMin) * float64(i) / float64(xDivs))
+			z := e.eval(&v)
+			ok := !math.IsNaN(z) && !math.IsInf(z, 0)
+			if !ok {
+				z = 0
+			}
+			o.P = append(o.P, Point{X: v.x, Y: v.y, Z: z})
+			valid = append(valid, ok)
+			if !ok {
+				z = math.NaN()
+			}
+			heights = append(heights, z)
+		}
+	}
+	o.Scalars = map[string][]float64{"z": heights}
+	idx := func(i int, j int) int {
+		return (j * (xDivs + 1)) + i
+	}
+	for j := 0; j < yDivs; j++ {
+		for i := 0; i < xDivs; i++ {
+			s := Surface{idx(i, j), idx(i+1, j), idx(i+1, j+1), idx(i, j+1)}
+			if valid[s[0]] && valid[s[1]] && valid[s[2]] && valid[s[3]] {
+				o.S = append(o.S, s)
+			}
+		}
+	}
+	o.E = edgesFromSurfaces(o.S)
+	return
+}
+
+// Parses and plots the given expression, replacing any existing plot.  An empty expression removes the plot
+func plotExpression(s string) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "z=") || strings.HasPrefix(s, "z =") {
+		s = strings.TrimSpace(s[strings.Index(s, "=")+1:])
+	}
+	if s == "" {
+		plotExpr = nil
+		delete(curves, plotName)
+		if n := findNode(sceneRoot, plotName); n != nil {
+			deleteNode(n)
+			updateWorldSpace()
+		}
+		return
+	}
+
+	// Several comma separated expressions are a parametric curve, rather than a surface
+	if len(splitTopLevel(s, ',')) > 1 || strings.Contains(s, ";") {
+		plotExpr = nil
+		err := plotCurveExpression(s)
+		if err != nil {
+			opText = fmt.Sprintf("Curve error: %v", err)
+			return
+		}
+		opText = fmt.Sprintf("Plotting curve (%v)", s)
+		return
+	}
+	e, err := parseExpr(s)
+	if err != nil {
+		opText = fmt.Sprintf("Expression error: %v", err)
+		return
+	}
+	plotExpr = e
+	plotStart = time.Now()
+	delete(curves, plotName)
+	opText = fmt.Sprintf("Plotting z = %v", s)
+	updatePlot()
+}
+
+// Samples the current function again, updating the plot in the scene
+func updatePlot() {
+	e := plotExpr
+	if e == nil {
+		return
+	}
+	t := time.Since(plotStart).Seconds()
+	o := plotSurface(e, plotXMin, plotXMax, plotYMin, plotYMax, plotDivs, plotDivs, t, plotColour)
+	n := findNode(sceneRoot, plotName)
+	if n == nil {
+		// The plot has lots of points, so start with its inspector section collapsed
+		n = addNode(sceneRoot, plotName, newMesh(plotName, o), 0, 0, 0)
+		inspectorCollapsed[plotName] = true
+	} else {
+		updateMesh(n.Mesh, o)
+	}
+	updateWorldSpace()
+}
+
+// Keeps plots of functions using time (t) moving
+func animatePlot() {
+	for {
+		time.Sleep(50 * time.Millisecond)
+		if plotExpr != nil && exprUses(plotExpr, 't') {
+			updatePlot()
+		}
+	}
+}
+
+// Watches the expression input field on the page, re-plotting as the user types.  When running in a worker, the page
+// sends us the expression instead
+func initPlotInput() {
+	if inWorker {
+		return
+	}
+	input := doc.Call("getElementById", "expression")
+	if input == js.Null() {
+		return
+	}
+	plotCall = js.NewCallback(func(args []js.Value) {
+		plotExpression(args[0].Get("target").Get("value").String())
+	})
+	input.Call("addEventListener", "input", plotCall)
+
+	// Pressing enter in the input field animates curves
+	plotChangeCall = js.NewCallback(func(args []js.Value) {
+		animateCurve()
+	})
+	input.Call("addEventListener", "change", plotChangeCall)
+}
+
+// Has the plotted curve (if there is one) draw itself from the start
+func animateCurve() {
+	if _, ok := curves[plotName]; !ok || renderActive.Load() {
+		return
+	}
+	queue <- Operation{op: REVEAL, target: plotName, t: 2000, f: 120}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// The generators for the standard primitives, with default sizes, so they can be created by name
+var primitives = map[string]func() Object{
+	"cube":      func() Object { return cube(2, "lightblue") },
+	"box":       func() Object { return box(3, 2, 1, "lightblue") },
+	"sphere":    func() Object { return uvSphere(1.5, 16, 8, "lightblue") },
+	"icosphere": func() Object { return icoSphere(1.5, 1, "lightblue") },
+	"cylinder":  func() Object { return cylinder(1, 2, 16, "lightblue") },
+	"cone":      func() Object { return cone(1, 2, 16, "lightblue") },
+	"torus":     func() Object { return torus(1.5, 0.5, 16, 8, "lightblue") },
+	"plane":     func() Object { return planeGrid(4, 4, 4, 4, "lightblue") },
+	"frame":     func() Object { return frame(4, 2, "lightblue") },
+	"prism":     func() Object { return prism(6, 1, 2, "lightblue") },
+	"pyramid":   func() Object { return pyramid(4, 1.5, 2, "lightblue") },
+}
+
+// Returns the names of the standard primitives, in alphabetical order
+func primitiveNames() (names []string) {
+	for name := range primitives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// All of the generators below create objects centred on their origin, with Z as the up direction.  Surfaces are wound
+// counter-clockwise when seen from outside.  They're marked as having AutoEdges, so their edges are worked out from
+// the surfaces when they're imported (and follow the feature angle)
+
+// Returns a cube with sides of the given length
+func cube(size float64, colour string) Object {
+	return box(size, size, size, colour)
+}
+
+// Returns a box with the given width (X), depth (Y), and height (Z)
+func box(w float64, d float64, h float64, colour string) (o Object) {
+	o.C = colour
+	for i := 0; i < 8; i++ {
+		// Point index bits are X, then Y, then Z
+		o.P = append(o.P, Point{
+			X: (float64(i&1) - 0.5) * w,
+			Y: (float64((i>>1)&1) - 0.5) * d,
+			Z: (float64((i>>2)&1) - 0.5) * h,
+		})
+	}
+	o.S = []Surface{
+		{0, 2, 3, 1}, // Bottom
+		{4, 5, 7, 6}, // Top
+		{0, 1, 5, 4}, // Front
+		{2, 6, 7, 3}, // Back
+		{0, 4, 6, 2}, // Left
+		{1, 3, 7, 5}, // Right
+	}
+	o.AutoEdges = true
+	return
+}
+
+// Returns a UV sphere, made of the given number of segments around the Z axis, and rings from pole to pole
+func uvSphere(radius float64, segments int, rings int, colour string) (o Object) {
+	segments = maxInt(segments, 3)
+	rings = maxInt(rings, 2)
+	o.C = colour
+
+	// The top pole, then each ring from the top down, then the bottom pole
+	o.P = append(o.P, Point{Z: radius})
+	for i := 1; i < rings; i++ {
+		theta := math.Pi * float64(i) / float64(rings)
+		z := radius * math.Cos(theta)
+		r := radius * math.Sin(theta)
+		for j := 0; j < segments; j++ {
+			phi := 2 * math.Pi * float64(j) / float64(segments)
+			o.P = append(o.P, Point{X: r * math.Cos(phi), Y: r * math.Sin(phi), Z: z})
+		}
+	}
+	bottom := len(o.P)
+	o.P = append(o.P, Point{Z: -radius})
+
+	ring := func(i int, j int) int {
+		return 1 + ((i - 1) * segments) + (j % segments)
+	}
+	for j := 0; j < segments; j++ {
+		o.S = append(o.S, Surface{0, ring(1, j), ring(1, j+1)})
+		for i := 1; i < rings-1; i++ {
+			o.S = append(o.S, Surface{ring(i, j), ring(i+1, j), ring(i+1, j+1), ring(i, j+1)})
+		}
+		o.S = append(o.S, Surface{bottom, ring(rings-1, j+1), ring(rings-1, j)})
+	}
+	o.AutoEdges = true
+	return
+}
+
+// Returns an icosphere, made by repeatedly splitting each triangle of an icosahedron into four
+func icoSphere(radius float64, subdivisions int, colour string) (o Object) {
+	o.C = colour
+	t := (1 + math.Sqrt(5)) / 2
+	pts := []Point{
+		{X: -1, Y: t}, {X: 1, Y: t}, {X: -1, Y: -t}, {X: 1, Y: -t},
+		{Y: -1, Z: t}, {Y: 1, Z: t}, {Y: -1, Z: -t}, {Y: 1, Z: -t},
+		{X: t, Z: -1}, {X: t, Z: 1}, {X: -t, Z: -1}, {X: -t, Z: 1},
+	}
+	faces := [][3]int{
+		{0, 11, 5}, {0, 5, 1}, {0, 1, 7}, {0, 7, 10}, {0, 10, 11},
+		{1, 5, 9}, {5, 11, 4}, {11, 10, 2}, {10, 7, 6}, {7, 1, 8},
+		{3, 9, 4}, {3, 4, 2}, {3, 2, 6}, {3, 6, 8}, {3, 8, 9},
+		{4, 9, 5}, {2, 4, 11}, {6, 2, 10}, {8, 6, 7}, {9, 8, 1},
+	}
+
+	// Split each triangle into four, sharing the new mid points between neighbouring triangles
+	for s := 0; s < subdivisions; s++ {
+		mids := make(map[[2]int]int)
+		mid := func(a int, b int) int {
+			k := [2]int{minInt(a, b), maxInt(a, b)}
+			if i, ok := mids[k]; ok {
+				return i
+			}
+			pts = append(pts, Point{X: (pts[a].X + pts[b].X) / 2, Y: (pts[a].Y + pts[b].Y) / 2, Z: (pts[a].Z + pts[b].Z) / 2})
+			mids[k] = len(pts) - 1
+			return len(pts) - 1
+		}
+		var split [][3]int
+		for _, f := range faces {
+			ab, bc, ca := mid(f[0], f[1]), mid(f[1], f[2]), mid(f[2], f[0])
+			split = append(split, [3]int{f[0], ab, ca}, [3]int{f[1], bc, ab}, [3]int{f[2], ca, bc}, [3]int{ab, bc, ca})
+		}
+		faces = split
+	}
+
+	// Push each point out onto the sphere
+	for _, p := range pts {
+		l := math.Sqrt(p.X*p.X + p.Y*p.Y + p.Z*p.Z)
+		o.P = append(o.P, Point{X: p.X / l * radius, Y: p.Y / l * radius, Z: p.Z / l * radius})
+	}
+	for _, f := range faces {
+		o.S = append(o.S, Surface{f[0], f[1], f[2]})
+	}
+	o.AutoEdges = true
+	return
+}
+
+// Returns a capped cylinder along the Z axis, approximated with the given number of segments
+func cylinder(radius float64, height float64, segments int, colour string) Object {
+	return prism(segments, radius, height, colour)
+}
+
+// Returns a cone along the Z axis, pointing up, approximated with the given number of segments
+func cone(radius float64, height float64, segments int, colour string) Object {
+	return pyramid(segments, radius, height, colour)
+}
+
+// Returns a regular prism along the Z axis, whose ends are polygons with the given number of sides
+func prism(sides int, radius float64, height float64, colour string) (o Object) {
+	sides = maxInt(sides, 3)
+	o.C = colour
+
+	// The bottom ring of points, then the top ring
+	for _, z := range []float64{-height / 2, height / 2} {
+		for j := 0; j < sides; j++ {
+			phi := 2 * math.Pi * float64(j) / float64(sides)
+			o.P = append(o.P, Point{X: radius * math.Cos(phi), Y: radius * math.Sin(phi), Z: z})
+		}
+	}
+	var top, bottom Surface
+	for j := 0; j < sides; j++ {
+		k := (j + 1) % sides
+		o.S = append(o.S, Surface{j, k, sides + k, sides + j})
+		top = append(top, sides+j)
+		bottom = append(bottom, sides-1-j)
+	}
+	o.S = append(o.S, top, bottom)
+	o.AutoEdges = true
+	return
+}
+
+// Returns a regular pyramid along the Z axis, pointing up, whose base is a polygon with the given number of sides
+func pyramid(sides int, radius float64, height float64, colour string) (o Object) {
+	sides = maxInt(sides, 3)
+	o.C = colour
+	for j := 0; j < sides; j++ {
+		phi := 2 * math.Pi * float64(j) / float64(sides)
+		o.P = append(o.P, Point{X: radius * math.Cos(phi), Y: radius * math.Sin(phi), Z: -height / 2})
+	}
+	apex := len(o.P)
+	o.P = append(o.P, Point{Z: height / 2})
+	var base Surface
+	for j := 0; j < sides; j++ {
+		o.S = append(o.S, Surface{j, (j + 1) % sides, apex})
+		base = append(base, sides-1-j)
+	}
+	o.S = append(o.S, base)
+	o.AutoEdges = true
+	return
+}
+
+// Returns a torus around the Z axis.  The major radius is from the centre to the middle of the tube, and the minor
+// radius is that of the tube itself
+func torus(major float64, minor float64, majorSegments int, minorSegments int, colour string) (o Object) {
+	majorSegments = maxInt(majorSegments, 3)
+	minorSegments = maxInt(minorSegments, 3)
+	o.C = colour
+	for i := 0; i < majorSegments; i++ {
+		phi := 2 * math.Pi * float64(i) / float64(majorSegments)
+		for j := 0; j < minorSegments; j++ {
+			theta := 2 * math.Pi * float64(j) / float64(minorSegments)
+			r := major + (minor * math.Cos(theta))
+			o.P = append(o.P, Point{X: r * math.Cos(phi), Y: r * math.Sin(phi), Z: minor * math.Sin(theta)})
+		}
+	}
+	idx := func(i int, j int) int {
+		return ((i % majorSegments) * minorSegments) + (j % minorSegments)
+	}
+	for i := 0; i < majorSegments; i++ {
+		for j := 0; j < minorSegments; j++ {
+			o.S = append(o.S, Surface{idx(i, j), idx(i+1, j), idx(i+1, j+1), idx(i, j+1)})
+		}
+	}
+	o.AutoEdges = true
+	return
+}
+
+// Returns a flat grid on the Z = 0 plane, facing up, with the given width (X) and depth (Y) split into the given
+// number of divisions
+func planeGrid(w float64, d float64, xDivs int, yDivs int, colour string) (o Object) {
+	xDivs = maxInt(xDivs, 1)
+	yDivs = maxInt(yDivs, 1)
+	o.C = colour
+	for j := 0; j <= yDivs; j++ {
+		for i := 0; i <= xDivs; i++ {
+			o.P = append(o.P, Point{
+				X: (float64(i)/float64(xDivs) - 0.5) * w,
+				Y: (float64(j)/float64(yDivs) - 0.5) * d,
+			})
+		}
+	}
+	idx := func(i int, j int) int {
+		return (j * (xDivs + 1)) + i
+	}
+	for j := 0; j < yDivs; j++ {
+		for i := 0; i < xDivs; i++ {
+			o.S = append(o.S, Surface{idx(i, j), idx(i+1, j), idx(i+1, j+1), idx(i, j+1)})
+		}
+	}
+	o.AutoEdges = true
+	return
+}
+
+// Returns a flat square on the Z = 0 plane, facing up, with a square hole in the middle.  This is a single surface with
+// a hole, rather than being made of several surfaces around the hole
+func frame(outer float64, inner float64, colour string) (o Object) {
+	o.C = colour
+	for _, size := range []float64{outer, inner} {
+		h := size / 2
+		o.P = append(o.P, Point{X: -h, Y: -h}, Point{X: h, Y: -h}, Point{X: h, Y: h}, Point{X: -h, Y: h})
+	}
+	o.S = []Surface{{0, 1, 2, 3}}
+	o.Holes = map[int][]Surface{0: {{7, 6, 5, 4}}}
+	o.AutoEdges = true
+	return
+}
+
+// Returns the edges around the outline of each surface, with the edges shared by neighbouring surfaces only included
+// once
+func edgesFromSurfaces(surfaces []Surface) (edges []Edge) {
+	seen := make(map[[2]int]bool)
+	for _, s := range surfaces {
+		for i, a := range s {
+			b := s[(i+1)%len(s)]
+			k := [2]int{minInt(a, b), maxInt(a, b)}
+			if a == b || seen[k] {
+				continue
+			}
+			seen[k] = true
+			edges = append(edges, Edge{a, b})
+		}
+	}
+	return
+}
+
+// Returns the smaller of two ints
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Returns the larger of two ints
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
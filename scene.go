@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// A node in the scene graph.  Each node has a transform relative to its parent, so moving a node also moves all of
+// its children
+type Node struct {
+	Name     string
+	Local    matrix // Transform of this node, relative to its parent
+	Mesh     *Mesh  // The mesh drawn at this node.  nil for grouping nodes
+	Colour   string // Overrides the colour of the mesh for this node, when not empty
+	Parent   *Node
+	Children []*Node
+}
+
+// Geometry which can be shared by any number of scene nodes.  Changes to a mesh show up in every node using it
+type Mesh struct {
+	Name string
+	Object
+	LODs []Object // Simplified copies for drawing when small on screen, most detailed first.  Built when first needed
+
+	lodsDone bool      // Whether all of the simplified copies have been built
+	changed  time.Time // When the geometry last changed, so the simplified copies aren't rebuilt while it's still changing
+	version  int       // Counts the changes to the geometry, so details worked out from it can tell when they're stale
+}
+
+// Records that a mesh's geometry has changed.  Its simplified copies are thrown away, to be built again once it
+// settles down
+func (m *Mesh) geometryChanged() {
+	m.LODs = nil
+	m.lodsDone = false
+	m.changed = time.Now()
+	m.version++
+}
+
+// The meshes used in the scene, by name
+var meshes map[string]*Mesh
+
+// Creates a new mesh from an object, numbering its points, and adds it to the mesh library
+func newMesh(name string, ob Object) *Mesh {
+	m := &Mesh{Name: name, Object: importObject(ob)}
+	meshes[name] = m
+	return m
+}
+
+// Returns the given name, with a number added if a node or mesh already uses it
+func uniqueMeshName(base string) string {
+	name := base
+	for i := 2; findNode(sceneRoot, name) != nil || meshes[name] != nil; i++ {
+		name = fmt.Sprintf("%s %d", base, i)
+	}
+	return name
+}
+
+// Replaces the geometry of a mesh.  Existing point numbers are kept, and only points beyond the old number of points
+// get new numbers, so meshes which are regenerated often (eg function plots) don't use up new numbers each time
+func updateMesh(m *Mesh, ob Object) {
+	for i := range ob.P {
+		if i < len(m.P) {
+			ob.P[i].Num = m.P[i].Num
+		} else {
+			ob.P[i].Num = pointCounter
+			pointCounter++
+		}
+	}
+	ob.Mid = midPoint(ob.P)
+	ob.T = triangulateObject(ob)
+	if ob.AutoEdges || (len(ob.E) == 0 && len(ob.S) > 0) {
+		ob.AutoEdges = true
+		ob.E = deriveEdges(ob)
+	}
+	m.Object = ob
+	m.geometryChanged()
+}
+
+// Adds an instance of a mesh to the scene graph as a new child of the parent node, placed at the given X, Y, and Z
+// co-ordinates in the parent's space.  The world space is looked up by node name, so a number is added to the name if
+// another node in the tree already uses it
+func addNode(parent *Node, name string, m *Mesh, x float64, y float64, z float64) *Node {
+	n := &Node{
+		Name:  uniqueNodeName(parent, name),
+		Local: translate(identityMatrix, x, y, z),
+		Mesh:  m,
+	}
+	addChild(parent, n)
+	return n
+}
+
+// Returns the given name, with a number added if a node in the same tree as n already uses it
+func uniqueNodeName(n *Node, base string) string {
+	root := n
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	name := base
+	for i := 2; findNode(root, name) != nil; i++ {
+		name = fmt.Sprintf("%s %d", base, i)
+	}
+	return name
+}
+
+// Returns whether any node in the scene uses the given mesh
+func meshInUse(m *Mesh) bool {
+	var walk func(n *Node) bool
+	walk = func(n *Node) bool {
+		if n.Mesh == m {
+			return true
+		}
+		for _, c := range n.Children {
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	return walk(sceneRoot)
+}
+
+// Returns the nodes in the scene which use the given mesh, parents before their children
+func meshNodes(m *Mesh) (nodes []*Node) {
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.Mesh == m {
+			nodes = append(nodes, n)
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(sceneRoot)
+	return
+}
+
+// Attaches a node as the last child of the parent node
+func addChild(parent *Node, n *Node) {
+	n.Parent = parent
+	parent.Children = append(parent.Children, n)
+}
+
+// Detaches a node (and its children) from its parent
+func removeNode(n *Node) {
+	p := n.Parent
+	if p == nil {
+		return
+	}
+	for i, c := range p.Children {
+		if c == n {
+			p.Children = append(p.Children[:i], p.Children[i+1:]...)
+			break
+		}
+	}
+	n.Parent = nil
+}
+
+// Removes a node and everything below it from the scene, along with any of their meshes no other node uses and
+// their cached measurements
+func deleteNode(n *Node) {
+	removeNode(n)
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if m := n.Mesh; m != nil && meshes[m.Name] == m && !meshInUse(m) {
+			delete(meshes, m.Name)
+		}
+		delete(areaCaches, n.Name)
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(n)
+}
+
+// Returns the node with the given name, searching the tree below (and including) the given node.  Returns nil if
+// there's no node with that name
+func findNode(n *Node, name string) *Node {
+	if n == nil {
+		return nil
+	}
+	if n.Name == name {
+		return n
+	}
+	for _, c := range n.Children {
+		if f := findNode(c, name); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// Returns the transform from a node's own co-ordinates into world space, by combining the transforms of the node and
+// all of its parents
+func worldMatrix(n *Node) matrix {
+	m := n.Local
+	for p := n.Parent; p != nil; p = p.Parent {
+		m = matrixMult(p.Local, m)
+	}
+	return m
+}
+
+// Applies a transformation operation to a node.  Operations on the scene root work in world space around the origin,
+// as they always have.  For other nodes, rotating and scaling happen around the node's own origin, and translating
+// moves the node within its parent's space
+func applyTransform(n *Node, op OperationType, m matrix) {
+	if n.Parent == nil || op == TRANSLATE {
+		n.Local = matrixMult(m, n.Local)
+		return
+	}
+	n.Local = matrixMult(n.Local, m)
+}
+
+// The scene node each object in the world space comes from, by name
+var worldNodes map[string]*Node
+
+// Works out the world space version of every object in the scene graph, by composing the transforms down the tree
+func updateWorldSpace() {
+	ws := make(map[string]Object, len(worldSpace))
+	nodes := make(map[string]*Node, len(worldSpace))
+	var walk func(n *Node, parentWorld matrix)
+	walk = func(n *Node, parentWorld matrix) {
+		w := matrixMult(parentWorld, n.Local)
+		if n.Mesh != nil {
+			// Only the points are copied, the edges and surfaces are shared with the mesh
+			o := n.Mesh.Object
+			o.P = make([]Point, len(n.Mesh.P))
+			for i, j := range n.Mesh.P {
+				o.P[i] = transform(w, j)
+			}
+			o.Mid = transform(w, n.Mesh.Mid)
+			if n.Colour != "" {
+				o.C = n.Colour
+			}
+			ws[n.Name] = o
+			nodes[n.Name] = n
+		}
+		for _, c := range n.Children {
+			walk(c, w)
+		}
+	}
+	walk(sceneRoot, identityMatrix)
+	worldSpace = ws
+	worldNodes = nodes
+	sceneMatrix = sceneRoot.Local
+}
+
+// Moves a point of a node's mesh to the given world space position.  As the mesh may be shared, this moves the point
+// in every node using the mesh
+func setWorldPoint(n *Node, idx int, p Point) {
+	inv, ok := invertMatrix(worldMatrix(n))
+	if !ok || n.Mesh == nil || idx >= len(n.Mesh.P) {
+		return
+	}
+	l := transform(inv, p)
+	l.Num = n.Mesh.P[idx].Num
+	n.Mesh.P[idx] = l
+	n.Mesh.Mid = midPoint(n.Mesh.P)
+	n.Mesh.T = triangulateObject(n.Mesh.Object)
+	if n.Mesh.AutoEdges && n.Mesh.FeatureAngle > 0 {
+		// Moving the point changes the angles between the surfaces, so the feature edges may have changed
+		n.Mesh.E = deriveEdges(n.Mesh.Object)
+	}
+	n.Mesh.geometryChanged()
+}
+
+// Returns the inverse of a 4x4 matrix.  The bool is false if the matrix can't be inverted (eg it has been scaled to
+// zero)
+func invertMatrix(m matrix) (matrix, bool) {
+	// Gauss-Jordan elimination, on the matrix alongside an identity matrix
+	a := make(matrix, 16)
+	copy(a, m)
+	inv := make(matrix, 16)
+	copy(inv, identityMatrix)
+	for col := 0; col < 4; col++ {
+		// Find the row with the largest value in this column, to keep things numerically stable
+		pivot := col
+		for r := col + 1; r < 4; r++ {
+			if math.Abs(a[r*4+col]) > math.Abs(a[pivot*4+col]) {
+				pivot = r
+			}
+		}
+		if a[pivot*4+col] == 0 {
+			return nil, false
+		}
+		for c := 0; c < 4; c++ {
+			a[col*4+c], a[pivot*4+c] = a[pivot*4+c], a[col*4+c]
+			inv[col*4+c], inv[pivot*4+c] = inv[pivot*4+c], inv[col*4+c]
+		}
+
+		// Scale the pivot row so the pivot becomes 1, then clear the column in the other rows
+		d := a[col*4+col]
+		for c := 0; c < 4; c++ {
+			a[col*4+c] /= d
+			inv[col*4+c] /= d
+		}
+		for r := 0; r < 4; r++ {
+			if r == col {
+				continue
+			}
+			f := a[r*4+col]
+			for c := 0; c < 4; c++ {
+				a[r*4+c] -= f * a[col*4+c]
+				inv[r*4+c] -= f * inv[col*4+c]
+			}
+		}
+	}
+	return inv, true
+}
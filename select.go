@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+var (
+	selected   string // Name of the selected object.  Empty when nothing is selected
+	previous   string // Name of the object selected before the current one, for operations on two objects
+	pickRadius = 10.0 // How close (in pixels) a click needs to be to a point to pick its object
+)
+
+// Returns where a world space point is drawn on the canvas, matching the layout used by renderFrame
+func screenPos(p Point) (float64, float64) {
+	step := math.Min(width, height) / 30
+	return (graphWidth / 2) + (p.X * step), (graphHeight / 2) - (p.Y * step)
+}
+
+// Returns the name of the object with a point closest to the given canvas position, and the index of that point.
+// Returns an empty name when no point is within pickRadius.  When points are the same distance away, the one nearer
+// the viewer wins.  Objects with hidden points can't be picked this way, as there's nothing to click on
+func pickPoint(x float64, y float64) (name string, idx int) {
+	best := pickRadius
+	bestZ := math.Inf(-1)
+	for n, o := range worldSpace {
+		if o.HidePoints {
+			continue
+		}
+		for i, p := range o.P {
+			if !validPoint(p) {
+				continue
+			}
+			px, py := screenPos(p)
+			d := math.Hypot(px-x, py-y)
+			if d < best || (d == best && p.Z > bestZ) {
+				name, idx, best, bestZ = n, i, d, p.Z
+			}
+		}
+	}
+	return
+}
+
+// Selects the named object, or clears the selection when the name is empty
+func selectObject(name string) {
+	if name == selected {
+		return
+	}
+	if selected != "" {
+		previous = selected
+	}
+	selected = name
+	apiEmit("selectionchange", map[string]interface{}{"selected": selected, "previous": previous})
+	if name == "" {
+		opText = "Nothing selected."
+		return
+	}
+	opText = fmt.Sprintf("Selected %v.  %v", name, boundsText(worldSpace[name]))
+}
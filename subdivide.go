@@ -0,0 +1,449 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// A change to the geometry of a mesh being animated, by moving its points from their start to their end positions
+type meshMorph struct {
+	mesh     *Mesh
+	from, to []Point
+}
+
+var (
+	smoothIterations = 3   // Number of smoothing passes done by the o key
+	smoothFactor     = 0.5 // How far each smoothing pass moves points towards the average of their neighbours
+)
+
+// Returns the faces to subdivide an object with.  Surfaces with holes can't be subdivided directly, so their triangles
+// are used instead
+func subdivisionFaces(o Object) (faces []Surface) {
+	tris := surfaceTriangles(o)
+	for i, s := range o.S {
+		if len(o.Holes[i]) == 0 {
+			faces = append(faces, s)
+			continue
+		}
+		for _, t := range tris[i] {
+			faces = append(faces, Surface{t.A, t.B, t.C})
+		}
+	}
+	return
+}
+
+// Returns a copy of an object with new points and surfaces, keeping its colour and display settings.  The original
+// points come first, so they keep their details given per point.  The added points get the same defaults as points
+// added in edit mode, apart from scalars which are spread out from the original points so colour mapping carries on
+// working
+func subdividedObject(o Object, pts []Point, faces []Surface) (r Object) {
+	r.C = o.C
+	r.P = pts
+	r.S = faces
+	r.HidePoints = o.HidePoints
+	r.HideLabels = o.HideLabels
+	r.FeatureAngle = o.FeatureAngle
+	r.AutoEdges = true
+	r.E = deriveEdges(r)
+
+	n, added := len(o.P), len(pts)-len(o.P)
+	if len(o.Labels) == n {
+		r.Labels = append(append([]string(nil), o.Labels...), make([]string, added)...)
+	}
+	if len(o.PColour) == n {
+		r.PColour = append([]string(nil), o.PColour...)
+		for i := 0; i < added; i++ {
+			r.PColour = append(r.PColour, theme.Point)
+		}
+	}
+	if len(o.PSize) == n {
+		r.PSize = append([]float64(nil), o.PSize...)
+		for i := 0; i < added; i++ {
+			r.PSize = append(r.PSize, 1)
+		}
+	}
+	if o.Scalars != nil {
+		r.Scalars = make(map[string][]float64, len(o.Scalars))
+		for k, v := range o.Scalars {
+			if len(v) == n {
+				r.Scalars[k] = spreadScalars(v, len(pts), faces)
+			}
+		}
+	}
+	r.ColourBy = o.ColourBy
+	r.ColourMap = o.ColourMap
+	return
+}
+
+// Returns scalar values for all of the points of a subdivided object, from the values of its original points.  Each
+// added point gets the average of its neighbours with values, a ring at a time, so face points (which only neighbour
+// other added points) are filled in after the edge points around them
+func spreadScalars(v []float64, count int, faces []Surface) []float64 {
+	vals := make([]float64, count)
+	for i := range vals {
+		vals[i] = math.NaN()
+		if i < len(v) {
+			vals[i] = v[i]
+		}
+	}
+	neighbours, _ := faceNeighbours(count, faces)
+	for changed := true; changed; {
+		changed = false
+		next := append([]float64(nil), vals...)
+		for i := len(v); i < count; i++ {
+			if !math.IsNaN(vals[i]) {
+				continue
+			}
+			sum, k := 0.0, 0
+			for nb := range neighbours[i] {
+				if !math.IsNaN(vals[nb]) {
+					sum += vals[nb]
+					k++
+				}
+			}
+			if k > 0 {
+				next[i] = sum / float64(k)
+				changed = true
+			}
+		}
+		vals = next
+	}
+	return vals
+}
+
+// Finds which points are next to each other along the surface edges, and which edges are on an open boundary (used by
+// only one surface)
+func faceNeighbours(n int, faces []Surface) (neighbours []map[int]bool, edgeFaces map[[2]int][]int) {
+	neighbours = make([]map[int]bool, n)
+	for i := range neighbours {
+		neighbours[i] = make(map[int]bool)
+	}
+	edgeFaces = make(map[[2]int][]int)
+	for f, s := range faces {
+		for j, a := range s {
+			b := s[(j+1)%len(s)]
+			if a == b {
+				continue
+			}
+			neighbours[a][b] = true
+			neighbours[b][a] = true
+			edgeFaces[edgeKey(a, b)] = append(edgeFaces[edgeKey(a, b)], f)
+		}
+	}
+	return
+}
+
+// Returns the new position of a point on an open boundary, which only takes its neighbours along the boundary into
+// account so the boundary keeps its shape.  The bool is false if the point isn't on a simple boundary
+func boundaryPosition(pts []Point, v int, neighbours map[int]bool, edgeFaces map[[2]int][]int) (Point, bool) {
+	var ends []int
+	for nb := range neighbours {
+		if len(edgeFaces[edgeKey(v, nb)]) == 1 {
+			ends = append(ends, nb)
+		}
+	}
+	if len(ends) == 0 {
+		return Point{}, false
+	}
+	if len(ends) != 2 {
+		// Corners where several boundaries meet stay put
+		return pts[v], true
+	}
+	p, a, b := pts[v], pts[ends[0]], pts[ends[1]]
+	return Point{X: 0.75*p.X + 0.125*(a.X+b.X), Y: 0.75*p.Y + 0.125*(a.Y+b.Y), Z: 0.75*p.Z + 0.125*(a.Z+b.Z)}, true
+}
+
+// Returns a weighted sum of points
+func weighted(pts []Point, idx []int, w []float64) (p Point) {
+	for i, n := range idx {
+		p.X += pts[n].X * w[i]
+		p.Y += pts[n].Y * w[i]
+		p.Z += pts[n].Z * w[i]
+	}
+	return
+}
+
+// Returns the average of the given points
+func average(pts []Point, idx []int) Point {
+	w := make([]float64, len(idx))
+	for i := range w {
+		w[i] = 1 / float64(len(idx))
+	}
+	return weighted(pts, idx, w)
+}
+
+// Splits each triangle of an object into four using Loop subdivision, which smooths the shape a bit more each time.
+// Surfaces which aren't triangles are split into triangles first.  The original points come first in the new object,
+// followed by a new point for each edge.  The start positions are where the new points sit before smoothing (the
+// original points, and the middle of each edge), for animating the change
+func loopSubdivide(o Object) (r Object, start []Point) {
+	var faces []Surface
+	for _, t := range surfaceTriangles(o) {
+		for _, tri := range t {
+			faces = append(faces, Surface{tri.A, tri.B, tri.C})
+		}
+	}
+	neighbours, edgeFaces := faceNeighbours(len(o.P), faces)
+	pts := make([]Point, len(o.P))
+	start = append([]Point(nil), o.P...)
+
+	// Move the original points towards their neighbours
+	for v, p := range o.P {
+		if bp, ok := boundaryPosition(o.P, v, neighbours[v], edgeFaces); ok {
+			pts[v] = bp
+			continue
+		}
+		n := len(neighbours[v])
+		if n == 0 {
+			pts[v] = p
+			continue
+		}
+		beta := 3.0 / 16
+		if n > 3 {
+			beta = 3 / (8 * float64(n))
+		}
+		idx := []int{v}
+		w := []float64{1 - float64(n)*beta}
+		for nb := range neighbours[v] {
+			idx = append(idx, nb)
+			w = append(w, beta)
+		}
+		pts[v] = weighted(o.P, idx, w)
+	}
+
+	// Add a point for each edge, pulled towards the far corners of the triangles on either side
+	edgePoint := make(map[[2]int]int)
+	mid := func(a int, b int) int {
+		k := edgeKey(a, b)
+		if i, ok := edgePoint[k]; ok {
+			return i
+		}
+		var p Point
+		idx := []int{a, b}
+		if fs := edgeFaces[k]; len(fs) == 2 {
+			for _, f := range fs {
+				for _, c := range faces[f] {
+					if c != a && c != b {
+						idx = append(idx, c)
+					}
+				}
+			}
+		}
+		if len(idx) == 4 {
+			p = weighted(o.P, idx, []float64{0.375, 0.375, 0.125, 0.125})
+		} else {
+			p = average(o.P, []int{a, b})
+		}
+		pts = append(pts, p)
+		start = append(start, average(o.P, []int{a, b}))
+		edgePoint[k] = len(pts) - 1
+		return len(pts) - 1
+	}
+	var split []Surface
+	for _, f := range faces {
+		a, b, c := f[0], f[1], f[2]
+		ab, bc, ca := mid(a, b), mid(b, c), mid(c, a)
+		split = append(split, Surface{a, ab, ca}, Surface{b, bc, ab}, Surface{c, ca, bc}, Surface{ab, bc, ca})
+	}
+	return subdividedObject(o, pts, split), start
+}
+
+// Splits each surface of an object into quads using Catmull-Clark subdivision, which smooths the shape a bit more each
+// time.  The original points come first in the new object, then a new point for each edge, then one for each surface.
+// The start positions are where the new points sit before smoothing, for animating the change
+func catmullClark(o Object) (r Object, start []Point) {
+	faces := subdivisionFaces(o)
+	neighbours, edgeFaces := faceNeighbours(len(o.P), faces)
+	facePts := make([]Point, len(faces))
+	for f, s := range faces {
+		facePts[f] = average(o.P, s)
+	}
+	pts := make([]Point, len(o.P))
+	start = append([]Point(nil), o.P...)
+
+	// Move the original points, using the average of the surrounding face points and edge middles
+	for v, p := range o.P {
+		if bp, ok := boundaryPosition(o.P, v, neighbours[v], edgeFaces); ok {
+			pts[v] = bp
+			continue
+		}
+		n := float64(len(neighbours[v]))
+		if n < 3 {
+			pts[v] = p
+			continue
+		}
+		var q, rr Point
+		var nf float64
+		seen := make(map[int]bool)
+		for nb := range neighbours[v] {
+			m := average(o.P, []int{v, nb})
+			rr = Point{X: rr.X + m.X/n, Y: rr.Y + m.Y/n, Z: rr.Z + m.Z/n}
+			for _, f := range edgeFaces[edgeKey(v, nb)] {
+				if !seen[f] {
+					seen[f] = true
+					q = Point{X: q.X + facePts[f].X, Y: q.Y + facePts[f].Y, Z: q.Z + facePts[f].Z}
+					nf++
+				}
+			}
+		}
+		q = Point{X: q.X / nf, Y: q.Y / nf, Z: q.Z / nf}
+		pts[v] = Point{X: (q.X + 2*rr.X + (n-3)*p.X) / n, Y: (q.Y + 2*rr.Y + (n-3)*p.Y) / n, Z: (q.Z + 2*rr.Z + (n-3)*p.Z) / n}
+	}
+
+	// Add a point for each edge, averaging its ends with the face points either side
+	edgePoint := make(map[[2]int]int)
+	mid := func(a int, b int) int {
+		k := edgeKey(a, b)
+		if i, ok := edgePoint[k]; ok {
+			return i
+		}
+		m := average(o.P, []int{a, b})
+		p := m
+		if fs := edgeFaces[k]; len(fs) == 2 {
+			f1, f2 := facePts[fs[0]], facePts[fs[1]]
+			p = Point{X: (2*m.X + f1.X + f2.X) / 4, Y: (2*m.Y + f1.Y + f2.Y) / 4, Z: (2*m.Z + f1.Z + f2.Z) / 4}
+		}
+		pts = append(pts, p)
+		start = append(start, m)
+		edgePoint[k] = len(pts) - 1
+		return len(pts) - 1
+	}
+	edgeMids := make([][]int, len(faces))
+	for f, s := range faces {
+		for j, a := range s {
+			edgeMids[f] = append(edgeMids[f], mid(a, s[(j+1)%len(s)]))
+		}
+	}
+
+	// Add the face points, and a quad for each corner of each face
+	var split []Surface
+	for f, s := range faces {
+		pts = append(pts, facePts[f])
+		start = append(start, facePts[f])
+		fp := len(pts) - 1
+		for j, a := range s {
+			prev := edgeMids[f][(j+len(s)-1)%len(s)]
+			split = append(split, Surface{a, edgeMids[f][j], fp, prev})
+		}
+	}
+	return subdividedObject(o, pts, split), start
+}
+
+// Moves each point part of the way towards the average of its neighbours, the given number of times.  Neighbours are
+// found along the edges and surfaces.  Points on the open boundary of the surfaces stay where they are, so flat sheets
+// keep their outline.  The points, edges, and surfaces are otherwise unchanged
+func laplacianSmooth(o Object, iterations int, factor float64) (r Object) {
+	r = o
+	neighbours, edgeFaces := faceNeighbours(len(o.P), o.S)
+	for _, e := range o.E {
+		if len(e) == 2 && e[0] != e[1] {
+			neighbours[e[0]][e[1]] = true
+			neighbours[e[1]][e[0]] = true
+		}
+	}
+	fixed := make([]bool, len(o.P))
+	for k, fs := range edgeFaces {
+		if len(fs) == 1 {
+			fixed[k[0]], fixed[k[1]] = true, true
+		}
+	}
+	pts := append([]Point(nil), o.P...)
+	for it := 0; it < iterations; it++ {
+		next := make([]Point, len(pts))
+		for v, p := range pts {
+			next[v] = p
+			if fixed[v] || len(neighbours[v]) == 0 || !validPoint(p) {
+				continue
+			}
+			var idx []int
+			for nb := range neighbours[v] {
+				if validPoint(pts[nb]) {
+					idx = append(idx, nb)
+				}
+			}
+			if len(idx) == 0 {
+				continue
+			}
+			a := average(pts, idx)
+			next[v] = Point{Num: p.Num, X: p.X + (a.X-p.X)*factor, Y: p.Y + (a.Y-p.Y)*factor, Z: p.Z + (a.Z-p.Z)*factor}
+		}
+		pts = next
+	}
+	r.P = pts
+	return
+}
+
+// Works out the result of a subdivision or smoothing operation on a node, and gives the node a new mesh for it so any
+// other nodes sharing the old mesh aren't changed.  The new mesh starts out looking the same as the old one, and the
+// returned morph moves it into its final shape
+func startMeshOp(n *Node, op Operation) (*meshMorph, error) {
+	if n.Mesh == nil {
+		return nil, fmt.Errorf("%v has no mesh", n.Name)
+	}
+	o := n.Mesh.Object
+	var r Object
+	var start []Point
+	var suffix string
+	switch op.op {
+	case LOOP:
+		r, start = loopSubdivide(o)
+		suffix = "loop"
+	case CATMULLCLARK:
+		r, start = catmullClark(o)
+		suffix = "cc"
+	case SMOOTH:
+		iterations := int(math.Max(1, op.X))
+		r = laplacianSmooth(o, iterations, op.Y)
+		start = o.P
+		suffix = "smooth"
+	}
+
+	// The new mesh starts from the start positions.  The original points come first in the new mesh, so they keep the
+	// same numbers as in the old one, and only the added points get new numbers
+	first := r
+	first.P = append([]Point(nil), start...)
+	old := n.Mesh
+	m := &Mesh{Name: uniqueMeshName(fmt.Sprintf("%v %v", old.Name, suffix)), Object: old.Object}
+	updateMesh(m, first)
+
+	// The points only move while morphing, so the triangles of the end shape are used all the way through.  Those of
+	// the start shape can be poor, as the added points start out on the old surfaces
+	m.T = triangulateObject(r)
+	meshes[m.Name] = m
+	n.Mesh = m
+	if !meshInUse(old) {
+		delete(meshes, old.Name)
+	}
+	return &meshMorph{mesh: m, from: start, to: r.P}, nil
+}
+
+// Moves the mesh's points the given fraction (0 to 1) of the way from their start to their end positions
+func (mm *meshMorph) step(f float64) {
+	if len(mm.mesh.P) != len(mm.to) {
+		// The mesh has been changed some other way, so there's nothing sensible to move
+		return
+	}
+	for i := range mm.mesh.P {
+		a, b := mm.from[i], mm.to[i]
+		mm.mesh.P[i].X = a.X + (b.X-a.X)*f
+		mm.mesh.P[i].Y = a.Y + (b.Y-a.Y)*f
+		mm.mesh.P[i].Z = a.Z + (b.Z-a.Z)*f
+	}
+	mm.mesh.Mid = midPoint(mm.mesh.P)
+	if mm.mesh.AutoEdges && mm.mesh.FeatureAngle > 0 {
+		mm.mesh.E = deriveEdges(mm.mesh.Object)
+	}
+	mm.mesh.geometryChanged()
+}
+
+// Queues a subdivision or smoothing operation on the selected object
+func queueMeshOp(op OperationType) {
+	if selected == "" {
+		opText = "Click on an object to select it first."
+		return
+	}
+	if renderActive.Load() {
+		return
+	}
+	queue <- Operation{op: op, target: selected, t: 1000, f: 40, X: float64(smoothIterations), Y: smoothFactor}
+}
@@ -0,0 +1,70 @@
+package main
+
+import "testing"
+
+func TestSubdivide(t *testing.T) {
+	tests := []struct {
+		name                 string
+		o                    Object
+		fn                   func(Object) (Object, []Point)
+		points, edges, faces int
+		euler                int
+		closed               bool
+	}{
+		{"loop tetrahedron", pyramid(3, 1, 1, "red"), loopSubdivide, 10, 24, 16, 2, true},
+		{"loop cube", cube(2, "red"), loopSubdivide, 26, 72, 48, 2, true},
+		{"catmull-clark cube", cube(2, "red"), catmullClark, 26, 48, 24, 2, true},
+		{"catmull-clark tetrahedron", pyramid(3, 1, 1, "red"), catmullClark, 14, 24, 12, 2, true},
+		{"catmull-clark grid", planeGrid(4, 4, 4, 4, "red"), catmullClark, 81, 144, 64, 1, false},
+	}
+	for _, tt := range tests {
+		o := importObject(tt.o)
+		r, start := tt.fn(o)
+		top := analyseTopology(r)
+		if len(r.P) != tt.points || top.Edges != tt.edges || len(r.S) != tt.faces {
+			t.Errorf("%v: got %d points, %d edges, and %d faces, wanted %d, %d, and %d", tt.name, len(r.P), top.Edges,
+				len(r.S), tt.points, tt.edges, tt.faces)
+		}
+		if len(r.E) != top.Edges {
+			t.Errorf("%v: has %d edges, but its surfaces have %d", tt.name, len(r.E), top.Edges)
+		}
+		if top.Euler != tt.euler || top.closed() != tt.closed {
+			t.Errorf("%v: the topology changed, to %v", tt.name, top)
+		}
+		if len(start) != len(r.P) {
+			t.Errorf("%v: got %d start positions for %d points", tt.name, len(start), len(r.P))
+			continue
+		}
+		for i, p := range o.P {
+			if start[i] != p {
+				t.Errorf("%v: original point %d doesn't start where it was", tt.name, i)
+				break
+			}
+		}
+	}
+}
+
+func TestLaplacianSmooth(t *testing.T) {
+	// A flat grid with a bump in the middle
+	o := importObject(planeGrid(4, 4, 4, 4, "red"))
+	o.P[12].Z = 1
+	r := laplacianSmooth(o, 3, 0.5)
+	if len(r.P) != len(o.P) || len(r.S) != len(o.S) || len(r.E) != len(o.E) {
+		t.Fatalf("smoothing changed the number of points, edges, or surfaces")
+	}
+	for j := 0; j <= 4; j++ {
+		for i := 0; i <= 4; i++ {
+			k := j*5 + i
+			edge := i == 0 || i == 4 || j == 0 || j == 4
+			if edge && r.P[k] != o.P[k] {
+				t.Errorf("boundary point %d moved from %v to %v", k, o.P[k], r.P[k])
+			}
+		}
+	}
+	if z := r.P[12].Z; z <= 0 || z >= 1 {
+		t.Errorf("the bump is at %v after smoothing, wanted between 0 and 1", z)
+	}
+	if r.P[12].Num != o.P[12].Num {
+		t.Error("smoothing changed a point number")
+	}
+}
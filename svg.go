@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Writes the given world space out as a standalone SVG document, using the same layout and draw order as renderFrame.
+// The side panel with the operation and point info is only included when withPanel is true.  Colours and fonts come
+// from the current theme
+func writeSVG(w io.Writer, ws map[string]Object, width float64, height float64, withPanel bool) error {
+	// Setup useful variables, matching those in renderFrame
+	border := float64(2)
+	gap := float64(3)
+	left := border + gap
+	top := border + gap
+	gWidth := width * 0.75
+	gHeight := height - 1
+	centerX := gWidth / 2
+	centerY := gHeight / 2
+	step := math.Min(width, height) / 30
+	docWidth := gWidth
+	if withPanel {
+		docWidth = width
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%0.0f" height="%0.0f" viewBox="0 0 %0.0f %0.0f">`+"\n",
+		docWidth, height, docWidth, height)
+	fmt.Fprintf(&b, `<defs><clipPath id="graph"><rect x="0" y="0" width="%0.2f" height="%0.2f"/></clipPath></defs>`+"\n",
+		gWidth, height)
+
+	// Clear the background
+	fmt.Fprintf(&b, `<rect x="0" y="0" width="%0.2f" height="%0.2f" fill="%s"/>`+"\n", docWidth, height, xmlEscape(theme.Background))
+
+	// Draw grid lines
+	fmt.Fprintf(&b, `<g clip-path="url(#graph)">`+"\n")
+	fmt.Fprintf(&b, `<g stroke="%s" stroke-dasharray="1 3">`+"\n", xmlEscape(theme.Grid))
+	for i := left; i < gWidth-step; i += step {
+		// Vertical dashed lines
+		svgLine(&b, i+step, top, i+step, gHeight)
+	}
+	for i := top; i < gHeight-step; i += step {
+		// Horizontal dashed lines
+		svgLine(&b, left, i+step, gWidth-border, i+step)
+	}
+	fmt.Fprintf(&b, "</g>\n")
+
+	// Draw the world axes, ground grid, and orientation gizmo
+	lines, labels := worldGuides(centerX, centerY, step, left, gHeight)
+	for _, l := range lines {
+		fmt.Fprintf(&b, `<line x1="%0.2f" y1="%0.2f" x2="%0.2f" y2="%0.2f" stroke="%s" stroke-width="%g"/>`+"\n",
+			l.x1, l.y1, l.x2, l.y2, xmlEscape(l.colour), l.width)
+	}
+	for _, l := range labels {
+		svgText(&b, l.x, l.y, l.colour, l.font, l.text)
+	}
+
+	// Draw the objects, in Z depth order
+	gradients := 0
+	for _, p := range depthOrder(ws) {
+		o := ws[p.name]
+		fmt.Fprintf(&b, "<g>\n")
+
+		// Draw the surfaces, furthest away first.  Each surface is one path made of its triangles, so there are no seams
+		// between them.  Objects coloured by a scalar get a gradient across each triangle instead
+		vals, lo, hi, cm, scalar := objectScalars(o)
+		tris := surfaceTriangles(o)
+		triPath := func(t Triangle) {
+			for m, n := range []int{t.A, t.B, t.C} {
+				if m == 0 {
+					b.WriteString("M")
+				} else {
+					b.WriteString(" L")
+				}
+				fmt.Fprintf(&b, "%0.2f,%0.2f", centerX+(o.P[n].X*step), centerY+((o.P[n].Y*step)*-1))
+			}
+			b.WriteString(" Z")
+		}
+		for _, si := range surfaceOrder(o) {
+			if len(tris[si]) == 0 {
+				continue
+			}
+			if !scalar {
+				fmt.Fprintf(&b, `<path fill="%s" d="`, xmlEscape(o.C))
+				for k, t := range tris[si] {
+					if k != 0 {
+						b.WriteString(" ")
+					}
+					triPath(t)
+				}
+				fmt.Fprintf(&b, `"/>`+"\n")
+				continue
+			}
+			for _, t := range tris[si] {
+				var fill string
+				x1, y1, x2, y2, stops := surfaceGradient(o, Surface{t.A, t.B, t.C}, vals, lo, hi, cm)
+				if len(stops) == 1 {
+					fill = xmlEscape(stops[0].colour)
+				} else {
+					gradients++
+					svgGradient(&b, fmt.Sprintf("grad%d", gradients), centerX+(x1*step), centerY-(y1*step),
+						centerX+(x2*step), centerY-(y2*step), stops)
+					fill = fmt.Sprintf("url(#grad%d)", gradients)
+				}
+				fmt.Fprintf(&b, `<path fill="%s" d="`, fill)
+				triPath(t)
+				fmt.Fprintf(&b, `"/>`+"\n")
+			}
+		}
+
+		// Draw the edges
+		fmt.Fprintf(&b, `<g stroke="%s" stroke-width="1" stroke-dasharray="2 4">`+"\n", xmlEscape(theme.Edge))
+		for _, l := range o.E {
+			svgLine(&b, centerX+(o.P[l[0]].X*step), centerY+((o.P[l[0]].Y*step)*-1),
+				centerX+(o.P[l[1]].X*step), centerY+((o.P[l[1]].Y*step)*-1))
+		}
+		fmt.Fprintf(&b, "</g>\n")
+
+		// Draw the points on the graph, with their labels
+		if o.HidePoints {
+			fmt.Fprintf(&b, "</g>\n")
+			continue
+		}
+		fmt.Fprintf(&b, `<g style="font: %s">`+"\n", xmlEscape(theme.PointFont))
+		if o.PColour == nil {
+			o.PColour = scalarPointColours(o)
+		}
+		for k, l := range o.P {
+			px := centerX + (l.X * step)
+			py := centerY + ((l.Y * step) * -1)
+			colour, radius := pointStyle(o, k)
+			fmt.Fprintf(&b, `<circle cx="%0.2f" cy="%0.2f" r="%g" fill="%s"/>`+"\n", px, py, radius, xmlEscape(colour))
+			if o.HideLabels {
+				continue
+			}
+			fmt.Fprintf(&b, `<text x="%0.2f" y="%0.2f" fill="%s">%s</text>`+"\n", px+5+radius-1, py+15, xmlEscape(theme.PointLabel),
+				xmlEscape(pointLabel(o, k)))
+		}
+		fmt.Fprintf(&b, "</g>\n</g>\n")
+	}
+
+	// Draw the bounding boxes and spheres, any surface problems, and the edit mode picks, over the top of the objects
+	guides := append(boundsGuides(ws, centerX, centerY, step), topologyGuides(ws, centerX, centerY, step)...)
+	for _, l := range append(guides, editGuides(ws, centerX, centerY, step)...) {
+		fmt.Fprintf(&b, `<line x1="%0.2f" y1="%0.2f" x2="%0.2f" y2="%0.2f" stroke="%s" stroke-width="%g"/>`+"\n",
+			l.x1, l.y1, l.x2, l.y2, xmlEscape(l.colour), l.width)
+	}
+
+	// Draw the measurements
+	lines, labels = measureGuides(ws, centerX, centerY, step)
+	for _, l := range lines {
+		fmt.Fprintf(&b, `<line x1="%0.2f" y1="%0.2f" x2="%0.2f" y2="%0.2f" stroke="%s" stroke-width="%g"/>`+"\n",
+			l.x1, l.y1, l.x2, l.y2, xmlEscape(l.colour), l.width)
+	}
+	for _, l := range labels {
+		svgText(&b, l.x, l.y, l.colour, l.font, l.text)
+	}
+
+	// Add the recent console output, as on the graph
+	for _, l := range consoleLabels(border+12, gHeight-24) {
+		svgText(&b, l.x, l.y, l.colour, l.font, l.text)
+	}
+	fmt.Fprintf(&b, "</g>\n")
+
+	if withPanel {
+		writeSVGPanel(&b, ws, gWidth, top)
+	}
+
+	// Draw a border around the graph area
+	fmt.Fprintf(&b, `<rect x="%0.2f" y="%0.2f" width="%0.2f" height="%0.2f" fill="none" stroke="%s" stroke-width="2"/>`+"\n",
+		border, border, gWidth-border, gHeight-border, xmlEscape(theme.GraphBorder))
+	fmt.Fprintf(&b, "</svg>\n")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// Writes the side panel text (current operation, and point co-ordinates) for an SVG export
+func writeSVGPanel(b *bytes.Buffer, ws map[string]Object, gWidth float64, top float64) {
+	textY := top + 20
+	svgText(b, gWidth+20, textY, theme.Heading, theme.HeadingFont, "Operation:")
+	textY += 20
+	svgText(b, gWidth+20, textY, theme.Text, theme.TextFont, opText)
+	textY += 30
+
+	// Add the colour bar legends, for objects coloured by a scalar
+	for i, bar := range colourBars(ws) {
+		svgText(b, gWidth+20, textY, theme.Heading, theme.HeadingFont, bar.title)
+		textY += 6
+		svgGradient(b, fmt.Sprintf("bar%d", i), gWidth+20, 0, gWidth+20+colourBarWidth, 0, bar.cm.stops(0, 1))
+		fmt.Fprintf(b, `<rect x="%0.2f" y="%0.2f" width="%0.2f" height="%0.2f" fill="url(#bar%d)" stroke="%s"/>`+"\n",
+			gWidth+20, textY, colourBarWidth, colourBarHeight, i, xmlEscape(theme.FieldBorder))
+		textY += colourBarHeight + 14
+		svgText(b, gWidth+20, textY, theme.Text, theme.TextFont, fmt.Sprintf("%.3g", bar.lo))
+		fmt.Fprintf(b, `<text x="%0.2f" y="%0.2f" fill="%s" style="font: %s" text-anchor="end">%.3g</text>`+"\n",
+			gWidth+20+colourBarWidth, textY, xmlEscape(theme.Text), xmlEscape(theme.TextFont), bar.hi)
+		textY += 20
+	}
+
+	// Add the results of the surface checks, when they're turned on
+	if showTopology {
+		svgText(b, gWidth+20, textY, theme.Heading, theme.HeadingFont, "Topology:")
+		for _, name := range sortedNames(ws) {
+			textY += 16
+			svgText(b, gWidth+20, textY, theme.Text, theme.ValueFont, fmt.Sprintf("%s: %v", name, analyseTopology(ws[name])))
+		}
+		textY += 20
+	}
+
+	// Add the measurements, when there are any
+	if len(measurements) > 0 {
+		svgText(b, gWidth+20, textY, theme.Heading, theme.HeadingFont, "Measurements:")
+		for _, m := range measurements {
+			textY += 16
+			svgText(b, gWidth+20, textY, theme.Text, theme.ValueFont, m.String())
+		}
+		textY += 20
+	}
+
+	// List the point co-ordinates grouped by object, in the same order as the inspector
+	for _, name := range sortedNames(ws) {
+		o := ws[name]
+		textY += inspectorRowHeight
+		svgText(b, gWidth+20, textY, theme.Heading, theme.HeadingFont, name)
+		fmt.Fprintf(b, `<rect x="%0.2f" y="%0.2f" width="12" height="12" fill="%s"/>`+"\n", gWidth+130, textY-11, xmlEscape(o.C))
+		for k, l := range o.P {
+			textY += inspectorRowHeight
+			svgText(b, gWidth+30, textY, theme.Heading, theme.HeadingFont, pointLabel(o, k)+":")
+			svgText(b, gWidth+100, textY, theme.Value, theme.ValueFont, fmt.Sprintf("(%0.1f, %0.1f, %0.1f)", l.X, l.Y, l.Z))
+		}
+	}
+}
+
+// Writes a single SVG line element
+func svgLine(b *bytes.Buffer, x1 float64, y1 float64, x2 float64, y2 float64) {
+	fmt.Fprintf(b, `<line x1="%0.2f" y1="%0.2f" x2="%0.2f" y2="%0.2f"/>`+"\n", x1, y1, x2, y2)
+}
+
+// Writes an SVG linear gradient, running between the given points
+func svgGradient(b *bytes.Buffer, id string, x1 float64, y1 float64, x2 float64, y2 float64, stops []gradientStop) {
+	fmt.Fprintf(b, `<linearGradient id="%s" gradientUnits="userSpaceOnUse" x1="%0.2f" y1="%0.2f" x2="%0.2f" y2="%0.2f">`,
+		id, x1, y1, x2, y2)
+	for _, s := range stops {
+		fmt.Fprintf(b, `<stop offset="%0.3f" stop-color="%s"/>`, s.pos, xmlEscape(s.colour))
+	}
+	fmt.Fprintf(b, "</linearGradient>\n")
+}
+
+// Writes a single SVG text element, using the given colour and canvas style font
+func svgText(b *bytes.Buffer, x float64, y float64, colour string, font string, txt string) {
+	fmt.Fprintf(b, `<text x="%0.2f" y="%0.2f" fill="%s" style="font: %s">%s</text>`+"\n", x, y, xmlEscape(colour),
+		xmlEscape(font), xmlEscape(txt))
+}
+
+// Escapes a string so it's safe to use as SVG text or attribute content
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriteSVG(t *testing.T) {
+	ws := map[string]Object{
+		"tri": importObject(Object{
+			C: "rgb(10, 20, 30)",
+			P: []Point{{X: -1, Y: -1}, {X: 1, Y: -1}, {X: 0, Y: 1}},
+			E: []Edge{{0, 1}, {1, 2}, {2, 0}},
+			S: []Surface{{0, 1, 2}},
+		}),
+	}
+	opText = "Rotate <a & b>"
+	tests := []struct {
+		name      string
+		withPanel bool
+		width     string // Width of the document
+	}{
+		{"graph only", false, "300"},
+		{"with panel", true, "400"},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := writeSVG(&b, ws, 400, 300, tt.withPanel); err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+
+		// Read back the elements, checking the document is well formed along the way
+		var root xml.StartElement
+		fills := make(map[string]bool)
+		var text strings.Builder
+		d := xml.NewDecoder(&b)
+		for {
+			tok, err := d.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%v: invalid SVG: %v", tt.name, err)
+			}
+			switch el := tok.(type) {
+			case xml.StartElement:
+				if root.Name.Local == "" {
+					root = el.Copy()
+				}
+				for _, a := range el.Attr {
+					if a.Name.Local == "fill" {
+						fills[a.Value] = true
+					}
+				}
+			case xml.CharData:
+				text.Write(el)
+			}
+		}
+		if root.Name.Local != "svg" {
+			t.Errorf("%v: the document is a %v, not an svg", tt.name, root.Name.Local)
+		}
+		for _, a := range root.Attr {
+			if a.Name.Local == "width" && a.Value != tt.width {
+				t.Errorf("%v: width is %v, wanted %v", tt.name, a.Value, tt.width)
+			}
+		}
+		if !fills[ws["tri"].C] {
+			t.Errorf("%v: nothing is filled with the object colour", tt.name)
+		}
+		if strings.Contains(text.String(), opText) != tt.withPanel {
+			t.Errorf("%v: the operation text should only be included with the panel", tt.name)
+		}
+	}
+}
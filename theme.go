@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"syscall/js"
+)
+
+// The colours and fonts used for drawing everything other than the objects themselves.  Colours and fonts use the same
+// syntax as the canvas fillStyle and font properties
+type Theme struct {
+	Name          string `json:"name"`
+	Background    string `json:"background"`    // Background of the whole canvas
+	Grid          string `json:"grid"`          // Dashed grid lines in the graph area
+	GraphBorder   string `json:"graphBorder"`   // Border around the graph area
+	Edge          string `json:"edge"`          // Dashed object edges
+	Point         string `json:"point"`         // Dots drawn for each point
+	PointLabel    string `json:"pointLabel"`    // Point labels on the graph
+	PointFont     string `json:"pointFont"`     // Font for the point labels on the graph
+	Heading       string `json:"heading"`       // Headings in the side panel
+	HeadingFont   string `json:"headingFont"`   // Font for the headings in the side panel
+	Text          string `json:"text"`          // General text in the side panel
+	TextFont      string `json:"textFont"`      // Font for general text in the side panel
+	Value         string `json:"value"`         // Values in the inspector
+	ValueFont     string `json:"valueFont"`     // Font for the values in the inspector
+	Help          string `json:"help"`          // Help text about the controls
+	Link          string `json:"link"`          // The source code link
+	LinkFont      string `json:"linkFont"`      // Font for the source code link
+	LinkHoverFont string `json:"linkHoverFont"` // Font for the source code link, when the mouse is over it
+	Recording     string `json:"recording"`     // The recording status text
+	ScrollBar     string `json:"scrollBar"`     // The inspector scroll bar
+	FieldBorder   string `json:"fieldBorder"`   // Border around the inspector value being edited
+	AxisX         string `json:"axisX"`         // The world X axis
+	AxisY         string `json:"axisY"`         // The world Y axis
+	AxisZ         string `json:"axisZ"`         // The world Z axis
+	AxisFont      string `json:"axisFont"`      // Font for the axis names
+	GroundGrid    string `json:"groundGrid"`    // The ground plane grid lines
+	TickLabel     string `json:"tickLabel"`     // The ground plane grid tick labels
+	TickFont      string `json:"tickFont"`      // Font for the ground plane grid tick labels
+	Bounds        string `json:"bounds"`        // Bounding boxes and spheres
+	Selected      string `json:"selected"`      // Bounding box of the selected object
+	Boundary      string `json:"boundary"`      // Open edges, used by only one surface
+	NonManifold   string `json:"nonManifold"`   // Edges used by more than two surfaces
+	BadWinding    string `json:"badWinding"`    // Edges between surfaces facing opposite ways
+	BadFace       string `json:"badFace"`       // Outlines of duplicate and degenerate surfaces
+	Measure       string `json:"measure"`       // Measurement dimension lines and values
+	MeasureFont   string `json:"measureFont"`   // Font for the measurement values on the graph
+	Console       string `json:"console"`       // Console output
+	ConsoleError  string `json:"consoleError"`  // Console error messages
+	ConsoleFont   string `json:"consoleFont"`   // Font for the console output
+}
+
+var (
+	lightTheme = Theme{
+		Name:          "light",
+		Background:    "white",
+		Grid:          "rgb(220, 220, 220)",
+		GraphBorder:   "black",
+		Edge:          "black",
+		Point:         "black",
+		PointLabel:    "black",
+		PointFont:     "12px sans-serif",
+		Heading:       "black",
+		HeadingFont:   "bold 14px serif",
+		Text:          "black",
+		TextFont:      "14px sans-serif",
+		Value:         "black",
+		ValueFont:     "12px sans-serif",
+		Help:          "blue",
+		Link:          "blue",
+		LinkFont:      "12px sans-serif",
+		LinkHoverFont: "bold 12px sans-serif",
+		Recording:     "red",
+		ScrollBar:     "rgb(200, 200, 200)",
+		FieldBorder:   "black",
+		AxisX:         "rgb(200, 40, 40)",
+		AxisY:         "rgb(40, 160, 40)",
+		AxisZ:         "rgb(40, 40, 200)",
+		AxisFont:      "bold 12px sans-serif",
+		GroundGrid:    "rgb(200, 200, 230)",
+		TickLabel:     "rgb(120, 120, 120)",
+		TickFont:      "10px sans-serif",
+		Bounds:        "rgb(230, 150, 0)",
+		Selected:      "rgb(255, 0, 200)",
+		Boundary:      "rgb(255, 140, 0)",
+		NonManifold:   "rgb(220, 0, 0)",
+		BadWinding:    "rgb(150, 0, 220)",
+		BadFace:       "rgb(0, 170, 170)",
+		Measure:       "rgb(200, 0, 120)",
+		MeasureFont:   "bold 12px sans-serif",
+		Console:       "rgb(40, 40, 40)",
+		ConsoleError:  "rgb(200, 0, 0)",
+		ConsoleFont:   "13px monospace",
+	}
+	darkTheme = Theme{
+		Name:          "dark",
+		Background:    "rgb(30, 30, 30)",
+		Grid:          "rgb(70, 70, 70)",
+		GraphBorder:   "rgb(200, 200, 200)",
+		Edge:          "rgb(220, 220, 220)",
+		Point:         "rgb(220, 220, 220)",
+		PointLabel:    "rgb(220, 220, 220)",
+		PointFont:     "12px sans-serif",
+		Heading:       "rgb(240, 240, 240)",
+		HeadingFont:   "bold 14px serif",
+		Text:          "rgb(220, 220, 220)",
+		TextFont:      "14px sans-serif",
+		Value:         "rgb(220, 220, 220)",
+		ValueFont:     "12px sans-serif",
+		Help:          "rgb(120, 170, 255)",
+		Link:          "rgb(120, 170, 255)",
+		LinkFont:      "12px sans-serif",
+		LinkHoverFont: "bold 12px sans-serif",
+		Recording:     "rgb(255, 100, 100)",
+		ScrollBar:     "rgb(90, 90, 90)",
+		FieldBorder:   "rgb(220, 220, 220)",
+		AxisX:         "rgb(255, 90, 90)",
+		AxisY:         "rgb(90, 220, 90)",
+		AxisZ:         "rgb(110, 140, 255)",
+		AxisFont:      "bold 12px sans-serif",
+		GroundGrid:    "rgb(60, 60, 90)",
+		TickLabel:     "rgb(150, 150, 150)",
+		TickFont:      "10px sans-serif",
+		Bounds:        "rgb(255, 190, 60)",
+		Selected:      "rgb(255, 90, 230)",
+		Boundary:      "rgb(255, 170, 50)",
+		NonManifold:   "rgb(255, 80, 80)",
+		BadWinding:    "rgb(190, 110, 255)",
+		BadFace:       "rgb(60, 220, 220)",
+		Measure:       "rgb(255, 110, 200)",
+		MeasureFont:   "bold 12px sans-serif",
+		Console:       "rgb(220, 220, 220)",
+		ConsoleError:  "rgb(255, 100, 100)",
+		ConsoleFont:   "13px monospace",
+	}
+
+	theme      = lightTheme
+	themeAuto  = true // If true, the theme follows the browser's prefers-color-scheme setting
+	schemeCall js.Callback
+)
+
+// Parses a JSON theme.  Anything not given in the JSON is taken from the light theme
+func parseTheme(data []byte) (t Theme, err error) {
+	t = lightTheme
+	t.Name = "custom"
+	err = json.Unmarshal(data, &t)
+	return
+}
+
+// Switches between the light and dark themes when the browser's colour scheme preference changes, unless the user has
+// picked a theme themselves
+func setColourScheme(dark bool) {
+	if !themeAuto {
+		return
+	}
+	if dark {
+		theme = darkTheme
+	} else {
+		theme = lightTheme
+	}
+}
+
+// Swaps between the light and dark themes.  From then on the browser's colour scheme preference is ignored
+func toggleTheme() {
+	themeAuto = false
+	if theme.Name == darkTheme.Name {
+		theme = lightTheme
+	} else {
+		theme = darkTheme
+	}
+}
+
+// Picks the initial theme.  A JSON theme can be given with a "theme" URL parameter, otherwise the browser's colour
+// scheme preference is followed
+func initTheme() {
+	// Workers don't have matchMedia, so the page sends us the colour scheme preference instead
+	if !inWorker {
+		mq := js.Global().Call("matchMedia", "(prefers-color-scheme: dark)")
+		setColourScheme(mq.Get("matches").Bool())
+		schemeCall = js.NewCallback(func(args []js.Value) {
+			setColourScheme(args[0].Get("matches").Bool())
+		})
+		mq.Call("addListener", schemeCall)
+	}
+
+	// The worker is started with the page's query string, so this works in both cases
+	search := js.Global().Get("location").Get("search").String()
+	params, err := url.ParseQuery(strings.TrimPrefix(search, "?"))
+	if err != nil || params.Get("theme") == "" {
+		return
+	}
+	loadThemeURL(params.Get("theme"))
+}
+
+// Fetches a JSON theme from the given URL, and switches to it once it arrives
+func loadThemeURL(u string) {
+	var resCall, textCall, errCall js.Callback
+	release := func() {
+		resCall.Release()
+		textCall.Release()
+		errCall.Release()
+	}
+	textCall = js.NewCallback(func(args []js.Value) {
+		defer release()
+		t, err := parseTheme([]byte(args[0].String()))
+		if err != nil {
+			opText = fmt.Sprintf("Error when parsing theme '%v': %v", u, err)
+			fmt.Println(opText)
+			return
+		}
+		themeAuto = false
+		theme = t
+	})
+	resCall = js.NewCallback(func(args []js.Value) {
+		if !args[0].Get("ok").Bool() {
+			opText = fmt.Sprintf("Error when fetching theme '%v': %v", u, args[0].Get("statusText").String())
+			fmt.Println(opText)
+			release()
+			return
+		}
+		args[0].Call("text").Call("then", textCall, errCall)
+	})
+
+	// Network errors and bad URLs reject the promise instead
+	errCall = js.NewCallback(func(args []js.Value) {
+		defer release()
+		opText = fmt.Sprintf("Error when fetching theme '%v': %v", u, args[0].Call("toString").String())
+		fmt.Println(opText)
+	})
+	js.Global().Call("fetch", u).Call("then", resCall, errCall)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// The results of checking an object's surfaces for problems
+type topology struct {
+	Vertices int // Points used by the surfaces
+	Edges    int // Distinct edges around the surfaces
+	Faces    int // Surfaces, not counting duplicate or degenerate ones
+	Holes    int // Holes cut into the surfaces
+	Euler    int // Euler characteristic, V - E + F, less one for each hole in a surface.  2 for a closed surface
+
+	Boundary    [][2]int // Edges used by only one surface, so the object is open there
+	NonManifold [][2]int // Edges used by more than two surfaces
+	BadWinding  [][2]int // Edges where both surfaces go the same way, so one of them faces the wrong way
+	Duplicate   []int    // Surfaces using the same points as an earlier surface
+	Degenerate  []int    // Surfaces with fewer than three different points, or no area
+	LoosePoints []int    // Points not used by any surface
+}
+
+var showTopology = false // If true, problems with the objects' surfaces are highlighted
+
+// Returns the key for an edge, which is the same whichever way around the edge goes
+func edgeKey(a int, b int) [2]int {
+	return [2]int{minInt(a, b), maxInt(a, b)}
+}
+
+// Checks the surfaces of an object for problems, and works out its Euler characteristic.  Duplicate and degenerate
+// surfaces are left out of the other checks, so they don't show up as problems twice
+func analyseTopology(o Object) (t topology) {
+	// Degenerate surfaces are those with almost no area, compared to the size of the object
+	box, ok := pointsAABB(o.P)
+	minArea := 0.0
+	if ok {
+		d := distance(box.Min, box.Max)
+		minArea = 1e-12 * d * d
+	}
+
+	type use struct {
+		face    int
+		forward bool // True if the surface goes from the lower numbered point to the higher one
+	}
+	uses := make(map[[2]int][]use)
+	var order [][2]int
+	seenFaces := make(map[string]bool)
+	used := make(map[int]bool)
+	for i, s := range o.S {
+		// Degenerate surfaces
+		distinct := make(map[int]bool)
+		for _, p := range s {
+			distinct[p] = true
+		}
+		n := polygonNormal(o.P, s)
+		if len(distinct) < 3 || math.Sqrt(n.X*n.X+n.Y*n.Y+n.Z*n.Z)/2 <= minArea {
+			t.Degenerate = append(t.Degenerate, i)
+			continue
+		}
+
+		// Duplicate surfaces, whichever point they start at or way they go around
+		sorted := append([]int(nil), s...)
+		sort.Ints(sorted)
+		key := fmt.Sprint(sorted)
+		if seenFaces[key] {
+			t.Duplicate = append(t.Duplicate, i)
+			continue
+		}
+		seenFaces[key] = true
+
+		// The outlines of any holes in the surface are part of its edges too
+		t.Faces++
+		for _, loop := range append([]Surface{s}, o.Holes[i]...) {
+			for j, a := range loop {
+				b := loop[(j+1)%len(loop)]
+				if a == b {
+					continue
+				}
+				used[a] = true
+				k := edgeKey(a, b)
+				if _, ok := uses[k]; !ok {
+					order = append(order, k)
+				}
+				uses[k] = append(uses[k], use{face: i, forward: a < b})
+			}
+		}
+		t.Holes += len(o.Holes[i])
+	}
+
+	// Sort the edges out by how many surfaces use them
+	for _, k := range order {
+		u := uses[k]
+		switch {
+		case len(u) == 1:
+			t.Boundary = append(t.Boundary, k)
+		case len(u) > 2:
+			t.NonManifold = append(t.NonManifold, k)
+		case u[0].forward == u[1].forward:
+			t.BadWinding = append(t.BadWinding, k)
+		}
+	}
+	for i := range o.P {
+		if !used[i] {
+			t.LoosePoints = append(t.LoosePoints, i)
+		}
+	}
+	t.Vertices = len(used)
+	t.Edges = len(order)
+	t.Euler = t.Vertices - t.Edges + t.Faces - t.Holes
+	return
+}
+
+// Returns true if the surfaces form a closed surface without any problems, so the object has a proper inside
+func (t topology) closed() bool {
+	return t.Faces > 0 && len(t.Boundary) == 0 && len(t.NonManifold) == 0 && len(t.BadWinding) == 0 &&
+		len(t.Duplicate) == 0 && len(t.Degenerate) == 0
+}
+
+// Returns a short summary of the check results, for the side panel
+func (t topology) String() string {
+	if t.Faces == 0 && len(t.Degenerate) == 0 && len(t.Duplicate) == 0 {
+		return "No surfaces"
+	}
+	var problems []string
+	add := func(n int, what string) {
+		if n > 0 {
+			problems = append(problems, fmt.Sprintf("%d %s", n, what))
+		}
+	}
+	add(len(t.Boundary), "open")
+	add(len(t.NonManifold), "non-manifold")
+	add(len(t.BadWinding), "flipped")
+	add(len(t.Duplicate), "duplicate")
+	add(len(t.Degenerate), "degenerate")
+	add(len(t.LoosePoints), "loose")
+	s := fmt.Sprintf("V %d, E %d, F %d, χ %d", t.Vertices, t.Edges, t.Faces, t.Euler)
+	if len(problems) == 0 {
+		return s + ", closed"
+	}
+	return s + ", " + strings.Join(problems, ", ")
+}
+
+// Returns the lines highlighting the problems found in each object, projected onto the graph.  Problem edges are drawn
+// thickly, and the outlines of bad surfaces are traced around
+func topologyGuides(ws map[string]Object, centerX float64, centerY float64, step float64) (lines []guideLine) {
+	if !showTopology {
+		return
+	}
+	for _, name := range sortedNames(ws) {
+		o := ws[name]
+		t := analyseTopology(o)
+		addEdge := func(a int, b int, colour string, w float64) {
+			p, q := o.P[a], o.P[b]
+			lines = append(lines, guideLine{x1: centerX + (p.X * step), y1: centerY - (p.Y * step), x2: centerX + (q.X * step),
+				y2: centerY - (q.Y * step), colour: colour, width: w})
+		}
+		for _, e := range t.Boundary {
+			addEdge(e[0], e[1], theme.Boundary, 2)
+		}
+		for _, e := range t.NonManifold {
+			addEdge(e[0], e[1], theme.NonManifold, 3)
+		}
+		for _, e := range t.BadWinding {
+			addEdge(e[0], e[1], theme.BadWinding, 3)
+		}
+		for _, f := range append(append([]int(nil), t.Duplicate...), t.Degenerate...) {
+			s := o.S[f]
+			for j, a := range s {
+				addEdge(a, s[(j+1)%len(s)], theme.BadFace, 3)
+			}
+		}
+	}
+	return
+}
+
+// Draws the check results for each object in the side panel, starting at the given height.  Returns the height below
+// them
+func drawTopology(textY float64) float64 {
+	if !showTopology {
+		return textY
+	}
+	textY += 20
+	ctx.Set("fillStyle", theme.Heading)
+	ctx.Set("font", theme.HeadingFont)
+	ctx.Call("fillText", "Topology:", graphWidth+20, textY)
+	ctx.Set("fillStyle", theme.Text)
+	ctx.Set("font", theme.ValueFont)
+	for _, name := range sortedNames(worldSpace) {
+		textY += 16
+		ctx.Call("fillText", fmt.Sprintf("%s: %v", name, analyseTopology(worldSpace[name])), graphWidth+20, textY)
+	}
+	return textY
+}
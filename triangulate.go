@@ -0,0 +1,304 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// A triangle of a surface, as worked out by triangulateObject.  The surfaces themselves are kept as they are, for
+// drawing outlines and editing, while the triangles are used for filling them
+type Triangle struct {
+	A, B, C int // Point indices, wound the same way as the surface
+	S       int // Index of the surface the triangle is part of
+}
+
+// A point projected onto the plane of a surface, along with its index in the object
+type flatPoint struct {
+	u, v float64
+	idx  int
+}
+
+// Splits every surface of an object into triangles, taking any holes in the surfaces into account
+func triangulateObject(o Object) (tris []Triangle) {
+	for i, s := range o.S {
+		for _, t := range triangulate(o.P, s, o.Holes[i]) {
+			tris = append(tris, Triangle{A: t[0], B: t[1], C: t[2], S: i})
+		}
+	}
+	return
+}
+
+// Returns the triangles of each surface, in surface order.  The triangles are worked out on the spot if the object
+// doesn't have them already
+func surfaceTriangles(o Object) [][]Triangle {
+	tris := o.T
+	if tris == nil {
+		tris = triangulateObject(o)
+	}
+	per := make([][]Triangle, len(o.S))
+	start := 0
+	for i := 1; i <= len(tris); i++ {
+		if i == len(tris) || tris[i].S != tris[start].S {
+			if s := tris[start].S; s < len(per) {
+				per[s] = tris[start:i]
+			}
+			start = i
+		}
+	}
+	return per
+}
+
+// Returns the surface indices of an object ordered by the average Z depth of their points, furthest away first, which is
+// the order they need drawing in
+func surfaceOrder(o Object) []int {
+	order := make([]int, len(o.S))
+	depth := make([]float64, len(o.S))
+	for i, s := range o.S {
+		order[i] = i
+		for _, p := range s {
+			depth[i] += o.P[p].Z
+		}
+		if len(s) > 0 {
+			depth[i] /= float64(len(s))
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return depth[order[a]] < depth[order[b]] })
+	return order
+}
+
+// Returns the normal of a polygon using Newell's method, which copes with polygons that aren't quite flat.  The length
+// of the normal is twice the polygon's area
+func polygonNormal(pts []Point, poly []int) (n Point) {
+	for i, a := range poly {
+		p, q := pts[a], pts[poly[(i+1)%len(poly)]]
+		n.X += (p.Y - q.Y) * (p.Z + q.Z)
+		n.Y += (p.Z - q.Z) * (p.X + q.X)
+		n.Z += (p.X - q.X) * (p.Y + q.Y)
+	}
+	return
+}
+
+// Splits a planar (or nearly planar) polygon into triangles using ear clipping.  Holes are joined onto the outline
+// first, with a pair of bridge edges each, so the whole thing can be clipped as a single polygon.  Any holes should be
+// wound the opposite way to the outline, although this is fixed up if they aren't
+func triangulate(pts []Point, outline Surface, holes []Surface) (tris [][3]int) {
+	if len(outline) < 3 {
+		return nil
+	}
+	if len(outline) == 3 && len(holes) == 0 {
+		return [][3]int{{outline[0], outline[1], outline[2]}}
+	}
+
+	// Flatten everything onto the plane the outline faces most, keeping the outline counter-clockwise
+	n := polygonNormal(pts, outline)
+	ax, ay, az := math.Abs(n.X), math.Abs(n.Y), math.Abs(n.Z)
+	flatten := func(poly Surface) []flatPoint {
+		f := make([]flatPoint, len(poly))
+		for i, idx := range poly {
+			p := pts[idx]
+			var u, v, sign float64
+			switch {
+			case az >= ax && az >= ay:
+				u, v, sign = p.X, p.Y, n.Z
+			case ax >= ay:
+				u, v, sign = p.Y, p.Z, n.X
+			default:
+				u, v, sign = p.Z, p.X, n.Y
+			}
+			if sign < 0 {
+				u, v = v, u
+			}
+			f[i] = flatPoint{u: u, v: v, idx: idx}
+		}
+		return f
+	}
+	poly := flatten(outline)
+	if signedArea(poly) < 0 {
+		// Only happens for badly twisted polygons, where Newell's normal isn't much use
+		reverseFlat(poly)
+	}
+
+	// Join the holes on, starting with the one reaching furthest along u
+	var flatHoles [][]flatPoint
+	for _, h := range holes {
+		if len(h) < 3 {
+			continue
+		}
+		fh := flatten(h)
+		if signedArea(fh) > 0 {
+			reverseFlat(fh)
+		}
+		flatHoles = append(flatHoles, fh)
+	}
+	sort.Slice(flatHoles, func(i, j int) bool { return maxU(flatHoles[i]) > maxU(flatHoles[j]) })
+	for _, h := range flatHoles {
+		poly = bridgeHole(poly, h)
+	}
+
+	// Tolerance for deciding when three points are in a line, scaled by the size of the polygon
+	minU, maxUV := math.Inf(1), 0.0
+	for _, p := range poly {
+		minU = math.Min(minU, math.Min(p.u, p.v))
+		maxUV = math.Max(maxUV, math.Max(p.u, p.v))
+	}
+	eps := 1e-10 * (maxUV - minU) * (maxUV - minU)
+
+	// Clip off ears until there's only a triangle left
+	for len(poly) > 3 {
+		clipped := false
+		for i := range poly {
+			a, b, c := poly[(i+len(poly)-1)%len(poly)], poly[i], poly[(i+1)%len(poly)]
+			cross := cross2(a, b, c)
+			if cross <= eps {
+				if math.Abs(cross) <= eps {
+					// Points in a line make no triangle, so just drop the middle one
+					poly = append(poly[:i], poly[i+1:]...)
+					clipped = true
+					break
+				}
+				continue
+			}
+			if earBlocked(poly, a, b, c) {
+				continue
+			}
+			tris = append(tris, [3]int{a.idx, b.idx, c.idx})
+			poly = append(poly[:i], poly[i+1:]...)
+			clipped = true
+			break
+		}
+		if !clipped {
+			// The polygon is too twisted for any proper ears, so clip the first convex looking corner (or failing that,
+			// the first corner) to make sure things finish
+			i := 0
+			for j := range poly {
+				if cross2(poly[(j+len(poly)-1)%len(poly)], poly[j], poly[(j+1)%len(poly)]) > 0 {
+					i = j
+					break
+				}
+			}
+			a, b, c := poly[(i+len(poly)-1)%len(poly)], poly[i], poly[(i+1)%len(poly)]
+			tris = append(tris, [3]int{a.idx, b.idx, c.idx})
+			poly = append(poly[:i], poly[i+1:]...)
+		}
+	}
+	if len(poly) == 3 && math.Abs(cross2(poly[0], poly[1], poly[2])) > eps {
+		tris = append(tris, [3]int{poly[0].idx, poly[1].idx, poly[2].idx})
+	}
+	return
+}
+
+// Returns true if any corner of the polygon, other than those of the ear itself, is inside the ear
+func earBlocked(poly []flatPoint, a flatPoint, b flatPoint, c flatPoint) bool {
+	same := func(p flatPoint, q flatPoint) bool {
+		return p.idx == q.idx || (p.u == q.u && p.v == q.v)
+	}
+	for _, p := range poly {
+		if same(p, a) || same(p, b) || same(p, c) {
+			continue
+		}
+		if cross2(a, b, p) >= 0 && cross2(b, c, p) >= 0 && cross2(c, a, p) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Joins a hole onto a polygon, by cutting in from the hole's furthest point along u to a corner of the polygon it can
+// see.  The result goes around the polygon, along the cut, around the hole, then back along the cut
+func bridgeHole(poly []flatPoint, hole []flatPoint) []flatPoint {
+	// The hole point furthest along u
+	m := 0
+	for i, p := range hole {
+		if p.u > hole[m].u {
+			m = i
+		}
+	}
+	mp := hole[m]
+
+	// Cast a ray from there along u, and find the nearest polygon edge it hits.  Only edges going up in v face the ray
+	// from inside the polygon, which also stops the ray hitting the far side of a bridge made for an earlier hole
+	best := -1
+	bestU := math.Inf(1)
+	for i := range poly {
+		a, b := poly[i], poly[(i+1)%len(poly)]
+		if a.v > mp.v || b.v <= mp.v {
+			continue
+		}
+		u := a.u + (mp.v-a.v)*(b.u-a.u)/(b.v-a.v)
+		if u >= mp.u && u < bestU {
+			best, bestU = i, u
+		}
+	}
+	if best == -1 {
+		// The hole isn't inside the polygon, so leave it out
+		return poly
+	}
+
+	// Join to the end of that edge which is furthest along u, unless other corners of the polygon are in the way.  In
+	// that case, use whichever of those is closest in angle to the ray
+	j := best
+	if poly[(best+1)%len(poly)].u > poly[best].u {
+		j = (best + 1) % len(poly)
+	}
+	hit := flatPoint{u: bestU, v: mp.v}
+	pj := poly[j]
+	bestAngle := math.Inf(1)
+	for i, p := range poly {
+		if i == j || p.u < mp.u || (p.u == pj.u && p.v == pj.v) {
+			// Copies of the chosen corner (from earlier bridges) are skipped, as they face a different part of the polygon
+			continue
+		}
+		if !inTriangle(mp, hit, pj, p) {
+			continue
+		}
+		angle := math.Abs(math.Atan2(p.v-mp.v, p.u-mp.u))
+		if angle < bestAngle {
+			bestAngle = angle
+			j = i
+		}
+	}
+
+	joined := make([]flatPoint, 0, len(poly)+len(hole)+2)
+	joined = append(joined, poly[:j+1]...)
+	for k := 0; k <= len(hole); k++ {
+		joined = append(joined, hole[(m+k)%len(hole)])
+	}
+	joined = append(joined, poly[j])
+	return append(joined, poly[j+1:]...)
+}
+
+// Returns true if p is inside (or on the edge of) the triangle abc, whichever way it's wound
+func inTriangle(a flatPoint, b flatPoint, c flatPoint, p flatPoint) bool {
+	d1, d2, d3 := cross2(a, b, p), cross2(b, c, p), cross2(c, a, p)
+	return (d1 >= 0 && d2 >= 0 && d3 >= 0) || (d1 <= 0 && d2 <= 0 && d3 <= 0)
+}
+
+// Returns the cross product of ab and bc.  Positive when the turn at b is counter-clockwise
+func cross2(a flatPoint, b flatPoint, c flatPoint) float64 {
+	return (b.u-a.u)*(c.v-b.v) - (b.v-a.v)*(c.u-b.u)
+}
+
+// Returns the signed area of a flattened polygon.  Positive when it's wound counter-clockwise
+func signedArea(poly []flatPoint) (a float64) {
+	for i, p := range poly {
+		q := poly[(i+1)%len(poly)]
+		a += (p.u * q.v) - (q.u * p.v)
+	}
+	return a / 2
+}
+
+// Reverses the order of a flattened polygon, in place
+func reverseFlat(poly []flatPoint) {
+	for i, j := 0, len(poly)-1; i < j; i, j = i+1, j-1 {
+		poly[i], poly[j] = poly[j], poly[i]
+	}
+}
+
+// Returns the largest u value of a flattened polygon
+func maxU(poly []flatPoint) float64 {
+	m := math.Inf(-1)
+	for _, p := range poly {
+		m = math.Max(m, p.u)
+	}
+	return m
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Returns the total area of a set of triangles
+func trianglesAreaOf(pts []Point, tris [][3]int) (a float64) {
+	for _, t := range tris {
+		// Half the length of the cross product of two of the sides
+		p, q, r := pts[t[0]], pts[t[1]], pts[t[2]]
+		ux, uy, uz := q.X-p.X, q.Y-p.Y, q.Z-p.Z
+		vx, vy, vz := r.X-p.X, r.Y-p.Y, r.Z-p.Z
+		a += math.Sqrt(math.Pow(uy*vz-uz*vy, 2)+math.Pow(uz*vx-ux*vz, 2)+math.Pow(ux*vy-uy*vx, 2)) / 2
+	}
+	return
+}
+
+func TestTriangulate(t *testing.T) {
+	square := []Point{{X: 0, Y: 0}, {X: 4, Y: 0}, {X: 4, Y: 4}, {X: 0, Y: 4}}
+	lShape := []Point{{X: 0, Y: 0}, {X: 4, Y: 0}, {X: 4, Y: 1}, {X: 1, Y: 1}, {X: 1, Y: 4}, {X: 0, Y: 4}}
+	withHole := append(append([]Point(nil), square...), Point{X: 1, Y: 1}, Point{X: 1, Y: 3}, Point{X: 3, Y: 3}, Point{X: 3, Y: 1})
+	upright := []Point{{X: 0, Y: 0, Z: 0}, {X: 2, Y: 0, Z: 0}, {X: 2, Y: 0, Z: 3}, {X: 0, Y: 0, Z: 3}}
+	tests := []struct {
+		name    string
+		pts     []Point
+		outline Surface
+		holes   []Surface
+		tris    int
+		area    float64
+	}{
+		{"triangle", square, Surface{0, 1, 2}, nil, 1, 8},
+		{"square", square, Surface{0, 1, 2, 3}, nil, 2, 16},
+		{"clockwise square", square, Surface{3, 2, 1, 0}, nil, 2, 16},
+		{"concave", lShape, Surface{0, 1, 2, 3, 4, 5}, nil, 4, 7},
+		{"hole", withHole, Surface{0, 1, 2, 3}, []Surface{{4, 5, 6, 7}}, 8, 12},
+		{"upright", upright, Surface{0, 1, 2, 3}, nil, 2, 6},
+	}
+	for _, tt := range tests {
+		tris := triangulate(tt.pts, tt.outline, tt.holes)
+		if len(tris) != tt.tris {
+			t.Errorf("%v: got %d triangles, want %d", tt.name, len(tris), tt.tris)
+		}
+		if a := trianglesAreaOf(tt.pts, tris); math.Abs(a-tt.area) > 1e-9 {
+			t.Errorf("%v: triangles cover an area of %v, want %v", tt.name, a, tt.area)
+		}
+	}
+}
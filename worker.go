@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"syscall/js"
+)
+
+var (
+	msgCall                   js.Callback
+	workerReady               chan struct{}
+	workerWidth, workerHeight float64 // The most recent canvas size sent to us by the page
+)
+
+// Sets up the message handler which receives the OffscreenCanvas and forwarded input events from the page, then waits
+// for the canvas to arrive
+func initWorker() {
+	workerReady = make(chan struct{})
+	msgCall = js.NewCallback(messageHandler)
+	js.Global().Call("addEventListener", "message", msgCall)
+
+	// Let the page know we're listening, so it can transfer the canvas across.  This also describes the wasmGraph API, so
+	// the page can offer it and pass the calls to us
+	js.Global().Call("postMessage", map[string]interface{}{"type": "ready", "api": apiDescription()})
+	<-workerReady
+}
+
+// Handles the messages sent to the worker by the page.  Input events arrive as plain objects carrying the same fields
+// as the original DOM events, so they're passed straight through to the normal handlers
+func messageHandler(args []js.Value) {
+	data := args[0].Get("data")
+	msgType := data.Get("type").String()
+	if debug {
+		fmt.Printf("Worker message type: %v\n", msgType)
+	}
+
+	switch msgType {
+	case "init":
+		// The page has transferred its canvas to us
+		canvasEl = data.Get("canvas")
+		workerWidth = data.Get("width").Float()
+		workerHeight = data.Get("height").Float()
+		width, height = workerWidth, workerHeight
+		canvasEl.Set("width", width)
+		canvasEl.Set("height", height)
+		ctx = canvasEl.Call("getContext", "2d")
+		close(workerReady)
+	case "colourScheme":
+		// Workers can't check prefers-color-scheme themselves, so the page tells us about it
+		setColourScheme(data.Get("dark").Bool())
+	case "resize":
+		// The renderer picks up the new size on the next frame
+		workerWidth = data.Get("width").Float()
+		workerHeight = data.Get("height").Float()
+	case "plot":
+		plotExpression(data.Get("expression").String())
+	case "plotAnimate":
+		animateCurve()
+	case "csv":
+		// A CSV file the user picked or dropped, which the page has read for us
+		loadCSV(data.Get("name").String(), data.Get("text").String())
+	case "console":
+		// A key the console input acts on.  The page puts whatever we send back into the input
+		value := consoleKey(data.Get("key").String(), data.Get("value").String())
+		js.Global().Call("postMessage", map[string]interface{}{"type": "consoleValue", "value": value})
+	case "api":
+		// A call to the wasmGraph API on the page
+		apiMessage(data)
+	case "apiWatch":
+		// The events the page has listeners for
+		apiWatch(data.Get("events"))
+	case "mousedown":
+		clickHandler([]js.Value{data.Get("event")})
+	case "keydown":
+		keypressHandler([]js.Value{data.Get("event")})
+	case "mousemove":
+		moveHandler([]js.Value{data.Get("event")})
+	case "mouseup":
+		mouseUpHandler([]js.Value{data.Get("event")})
+	case "wheel":
+		wheelHandler([]js.Value{data.Get("event")})
+	}
+}
@@ -0,0 +1,7 @@
+// Runs the wasm module inside a Web Worker.  The page transfers an OffscreenCanvas to us, and forwards its input events
+importScripts('wasm_exec.js');
+
+const go = new Go();
+WebAssembly.instantiateStreaming(fetch('main.wasm'), go.importObject).then(res => {
+    go.run(res.instance)
+})